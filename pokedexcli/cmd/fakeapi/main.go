@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/abi01shek/pokedexcli/pkg/fakeapi"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8000", "address to serve the fake PokeAPI on")
+	flag.Parse()
+
+	fmt.Printf("Serving fake PokeAPI, play against it with -base-url http://%s/api/v2/\n", *addr)
+	if err := http.ListenAndServe(*addr, fakeapi.NewHandler()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,9 +1,38 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
 	"github.com/abi01shek/pokedexcli/pkg/handlers"
 )
 
 func main() {
-	handlers.StartRepl()
+	opts := handlers.Options{
+		Retry:     apiCalls.DefaultRetryPolicy,
+		RateLimit: apiCalls.DefaultRateLimit,
+		RateBurst: apiCalls.DefaultRateBurst,
+	}
+	flag.StringVar(&opts.BaseURL, "base-url", "", "PokeAPI address, overrides $"+handlers.BaseURLEnv+" and base_url in ~/.pokedexcli/config.json (default "+apiCalls.DefaultBaseURL+")")
+	flag.IntVar(&opts.PageSize, "page-size", 0, "locations per map page, overrides page_size in ~/.pokedexcli/config.json (default 20)")
+	flag.StringVar(&opts.Offline, "offline", "", "play without network from a directory or zip of PokeAPI JSON")
+	flag.StringVar(&opts.Record, "record", "", "record every API request and response to a cassette file")
+	flag.StringVar(&opts.Replay, "replay", "", "replay the API responses of a recorded cassette file without network")
+	flag.BoolVar(&opts.Prefetch, "prefetch", false, "download all areas and pokemon into the response cache, which expires after 24h, and exit")
+	flag.StringVar(&opts.PrefetchBundle, "prefetch-bundle", "", "download all areas and pokemon into a bundle directory for -offline and exit")
+	flag.BoolVar(&opts.Verbose, "verbose", false, "print every API request and retry")
+	flag.StringVar(&opts.Version, "game-version", "", "only show pokemon of this game version, like diamond or pearl (default all)")
+	flag.IntVar(&opts.Retry.MaxAttempts, "retries", opts.Retry.MaxAttempts, "attempts per API request, 1 disables retries")
+	flag.DurationVar(&opts.Retry.BaseDelay, "retry-delay", opts.Retry.BaseDelay, "delay before the first retry, doubled for every further retry")
+	flag.DurationVar(&opts.Retry.MaxDelay, "retry-max-delay", opts.Retry.MaxDelay, "longest delay between two attempts")
+	flag.Float64Var(&opts.RateLimit, "rps", opts.RateLimit, "API requests per second, 0 disables the limit")
+	flag.IntVar(&opts.RateBurst, "burst", opts.RateBurst, "API requests allowed at once before the rate limit applies")
+	flag.Parse()
+
+	if err := handlers.StartRepl(opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -1,24 +1,341 @@
 package apiCalls
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/abi01shek/pokedexcli/pkg/pokecache"
 )
 
-// GetBodyApiCall takes in an address, does an api call and returns the body and error if any
-func GetBodyApiCall(addr string) ([]byte, error) {
-	res, err := http.Get(addr)
+// DefaultBaseURL is the address of the public PokeAPI
+const DefaultBaseURL = "https://pokeapi.co/api/v2/"
+
+// DefaultPageSize is the number of location areas PokeAPI lists per page
+// when no limit is given
+const DefaultPageSize = 20
+
+const defaultTimeout = 30 * time.Second
+const cacheExpiration = 5 * time.Minute
+const diskCacheExpiration = 24 * time.Hour
+const revalidateWindow = 30 * 24 * time.Hour
+const staleGrace = 7 * 24 * time.Hour
+const memCacheMaxBytes = 16 << 20
+const decodedCacheMaxEntries = 200
+
+// ErrNotFound is returned when PokeAPI has no resource with the requested name
+var ErrNotFound = errors.New("not found")
+
+// Client fetches PokeAPI resources and decodes them. Responses are cached
+// both raw, optionally on disk, and decoded. All requests of a client share
+// one rate limit.
+type Client struct {
+	baseURL           string
+	httpClient        *http.Client
+	cacheDir          string
+	retry             RetryPolicy
+	logf              func(format string, args ...any)
+	limiter           *rateLimiter // nil when requests are not limited
+	onRateLimitWait   func(d time.Duration)
+	offline           fs.FS     // answers requests instead of the network when set
+	cassette          *Cassette // replays a recorded session instead of the network when set
+	recorder          *Recorder
+	locationAreaLists *resource[LocationAreaList]
+	locationAreas     *resource[LocationArea]
+	pokemon           *resource[Pokemon]
+	species           *resource[PokemonSpecies]
+	items             *resource[Item]
+	versions          *resource[Version]
+}
+
+// ClientOption configures optional behaviour of a Client
+type ClientOption func(c *Client)
+
+// WithHTTPClient: use hc for requests instead of a client with a default timeout
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithCacheDir: keep responses on disk under dir so they survive restarts
+func WithCacheDir(dir string) ClientOption {
+	return func(c *Client) {
+		c.cacheDir = dir
+	}
+}
+
+// WithLogf: report requests and retries through logf, for verbose output
+func WithLogf(logf func(format string, args ...any)) ClientOption {
+	return func(c *Client) {
+		c.logf = logf
+	}
+}
+
+// NewClient creates a client for the PokeAPI at baseURL, for example DefaultBaseURL
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	c := new(Client)
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	c.baseURL = baseURL
+	c.httpClient = &http.Client{Timeout: defaultTimeout}
+	c.retry = DefaultRetryPolicy
+	c.limiter = newRateLimiter(DefaultRateLimit, DefaultRateBurst)
+	c.logf = func(format string, args ...any) {}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.offline != nil {
+		// the bundle is local already and never busy
+		c.httpClient = &http.Client{Transport: newOfflineTransport(c.offline, c.baseURL)}
+		c.cacheDir = ""
+		c.limiter = nil
+	}
+	if c.cassette != nil {
+		c.httpClient = &http.Client{Transport: c.cassette}
+		c.cacheDir = ""
+		c.limiter = nil
+	}
+	if c.recorder != nil {
+		// a disk cache would hide requests from the cassette and from its replay
+		next := c.httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc := *c.httpClient
+		hc.Transport = &recordTransport{next: next, rec: c.recorder}
+		c.httpClient = &hc
+		c.cacheDir = ""
+	}
+
+	// maps and areas rarely change, show them right away even when expired
+	c.locationAreaLists = newResource[LocationAreaList](c.newCache("location", pokecache.WithStaleWhileRevalidate(staleGrace)))
+	c.locationAreas = newResource[LocationArea](c.newCache("explore", pokecache.WithStaleWhileRevalidate(staleGrace)))
+	c.pokemon = newResource[Pokemon](c.newCache("pokemon"))
+	c.species = newResource[PokemonSpecies](c.newCache("species"))
+	c.items = newResource[Item](c.newCache("item"))
+	c.versions = newResource[Version](c.newCache("version"))
+	return c
+}
+
+// newCache: creates a cache for raw responses that is also kept on disk
+// when the client has a cache directory
+func (c *Client) newCache(name string, extraOpts ...pokecache.Option) *pokecache.Cache {
+	opts := []pokecache.Option{
+		pokecache.WithMaxBytes(memCacheMaxBytes),
+		pokecache.WithRevalidateWindow(revalidateWindow),
+	}
+	opts = append(opts, extraOpts...)
+	if c.cacheDir != "" {
+		opts = append(opts, pokecache.WithDiskDir(filepath.Join(c.cacheDir, name), diskCacheExpiration))
+	}
+	return pokecache.NewCache(cacheExpiration, opts...)
+}
+
+// Start: starts cleaning expired entries out of the caches until ctx is
+// cancelled or the client is closed
+func (c *Client) Start(ctx context.Context) {
+	c.locationAreaLists.start(ctx)
+	c.locationAreas.start(ctx)
+	c.pokemon.start(ctx)
+	c.species.start(ctx)
+	c.items.start(ctx)
+	c.versions.start(ctx)
+}
+
+// Close: stops the cache clean up and waits for background refreshes
+func (c *Client) Close() {
+	c.locationAreaLists.close()
+	c.locationAreas.close()
+	c.pokemon.close()
+	c.species.close()
+	c.items.close()
+	c.versions.close()
+}
+
+// CacheStats is the memory usage of one of the client caches
+type CacheStats struct {
+	Name string
+	pokecache.Stats
+}
+
+// CacheStats: returns the memory usage of all caches of the client
+func (c *Client) CacheStats() []CacheStats {
+	return []CacheStats{
+		{"location", c.locationAreaLists.raw.Stats()},
+		{"explore", c.locationAreas.raw.Stats()},
+		{"pokemon", c.pokemon.raw.Stats()},
+		{"species", c.species.raw.Stats()},
+		{"item", c.items.raw.Stats()},
+		{"version", c.versions.raw.Stats()},
+		{"decoded location", c.locationAreaLists.decoded.Stats()},
+		{"decoded explore", c.locationAreas.decoded.Stats()},
+		{"decoded pokemon", c.pokemon.decoded.Stats()},
+		{"decoded species", c.species.decoded.Stats()},
+		{"decoded item", c.items.decoded.Stats()},
+		{"decoded version", c.versions.decoded.Stats()},
+	}
+}
+
+// ListLocationAreas returns up to limit location areas starting at offset,
+// where the first area has offset zero. Count of the returned list is the
+// total number of areas.
+func (c *Client) ListLocationAreas(ctx context.Context, offset int, limit int) (LocationAreaList, error) {
+	if offset < 0 || limit < 1 {
+		return LocationAreaList{}, fmt.Errorf("invalid location area page: offset %d, limit %d", offset, limit)
+	}
+	list, stale, err := c.locationAreaLists.get(ctx, c, c.locationAreaPageURL(offset, limit))
+	list.Stale = stale
+	return list, err
+}
+
+// locationAreaPageURL: the address of a location-area list page, written
+// the way the next and previous links of PokeAPI are so both share a cache
+// entry
+func (c *Client) locationAreaPageURL(offset int, limit int) string {
+	return fmt.Sprintf("%slocation-area/?offset=%d&limit=%d", c.baseURL, offset, limit)
+}
+
+// GetLocationArea returns the location area with the given name
+func (c *Client) GetLocationArea(ctx context.Context, name string) (LocationArea, error) {
+	area, stale, err := c.locationAreas.get(ctx, c, c.baseURL+"location-area/"+url.PathEscape(name))
+	area.Stale = stale
+	return area, err
+}
+
+// GetPokemon returns the pokemon with the given name
+func (c *Client) GetPokemon(ctx context.Context, name string) (Pokemon, error) {
+	pokemon, _, err := c.pokemon.get(ctx, c, c.baseURL+"pokemon/"+url.PathEscape(name))
+	return pokemon, err
+}
+
+// GetPokemonSpecies returns the pokemon-species with the given name. The
+// species of a pokemon is named in its Species field.
+func (c *Client) GetPokemonSpecies(ctx context.Context, name string) (PokemonSpecies, error) {
+	species, _, err := c.species.get(ctx, c, c.baseURL+"pokemon-species/"+url.PathEscape(name))
+	return species, err
+}
+
+// GetItem returns the item with the given name
+func (c *Client) GetItem(ctx context.Context, name string) (Item, error) {
+	item, _, err := c.items.get(ctx, c, c.baseURL+"item/"+url.PathEscape(name))
+	return item, err
+}
+
+// GetVersion returns the game version with the given name
+func (c *Client) GetVersion(ctx context.Context, name string) (Version, error) {
+	version, _, err := c.versions.get(ctx, c, c.baseURL+"version/"+url.PathEscape(name))
+	return version, err
+}
+
+// getConditional does an api call for addr. When a stale cache entry is given its
+// validators are sent along and a 304 Not Modified returns the stale entry. The
+// returned entry carries the validators of the response for the next revalidation.
+// Transient failures are retried according to the retry policy of the client.
+func (c *Client) getConditional(ctx context.Context, addr string, stale *pokecache.Entry) (pokecache.Entry, error) {
+	res, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+		if err != nil {
+			return nil, err
+		}
+		if stale != nil {
+			if stale.ETag != "" {
+				req.Header.Set("If-None-Match", stale.ETag)
+			}
+			if stale.LastModified != "" {
+				req.Header.Set("If-Modified-Since", stale.LastModified)
+			}
+		}
+		return req, nil
+	})
 	if err != nil {
-		return nil, err
+		return pokecache.Entry{}, err
+	}
+	if res.statusCode == http.StatusNotModified && stale != nil {
+		return *stale, nil
+	}
+	if res.statusCode == http.StatusNotFound {
+		return pokecache.Entry{}, fmt.Errorf("%s: %w", addr, ErrNotFound)
+	}
+	if res.statusCode > 299 {
+		return pokecache.Entry{}, fmt.Errorf("response failed with status code: %d and body: %s", res.statusCode, res.body)
+	}
+	return pokecache.Entry{
+		Val:          res.body,
+		ETag:         res.header.Get("ETag"),
+		LastModified: res.header.Get("Last-Modified"),
+	}, nil
+}
+
+// resource caches one kind of PokeAPI resource, raw and decoded
+type resource[V any] struct {
+	raw     *pokecache.Cache
+	decoded *pokecache.TypedCache[string, V]
+}
+
+func newResource[V any](raw *pokecache.Cache) *resource[V] {
+	return &resource[V]{
+		raw:     raw,
+		decoded: pokecache.NewTypedCache[string, V](cacheExpiration, decodedCacheMaxEntries),
+	}
+}
+
+// get: Gets the decoded resource at addr from the decoded cache, otherwise
+// fetches the raw body through the raw cache and decodes it. Also reports
+// whether stale data was served while it is being refreshed.
+func (r *resource[V]) get(ctx context.Context, c *Client, addr string) (V, bool, error) {
+	if val, found := r.decoded.Get(addr); found {
+		return val, false, nil
 	}
-	body, err := io.ReadAll(res.Body)
-	res.Body.Close()
-	if res.StatusCode > 299 {
-		return nil, fmt.Errorf("response failed with status code: %d and body: %s", res.StatusCode, body)
+
+	var val V
+	entry, err := r.load(ctx, c, addr)
+	if err != nil {
+		return val, false, err
 	}
+	err = json.Unmarshal(entry.Val, &val)
 	if err != nil {
-		return nil, err
+		return val, false, err
 	}
-	return body, nil
+	// stale data is not kept, the next call picks up the refreshed entry
+	if !entry.Stale {
+		r.decoded.Add(addr, val)
+	}
+	return val, entry.Stale, nil
+}
+
+// load: gets the raw entry at addr through the raw cache
+func (r *resource[V]) load(ctx context.Context, c *Client, addr string) (pokecache.Entry, error) {
+	return r.raw.GetOrLoadEntry(ctx, addr, func(ctx context.Context, stale *pokecache.Entry) (pokecache.Entry, error) {
+		return c.getConditional(ctx, addr, stale)
+	})
+}
+
+// cached: the raw body at addr if it is cached and fresh
+func (r *resource[V]) cached(addr string) ([]byte, bool) {
+	return r.raw.Get(addr)
+}
+
+// fetch: gets the raw body at addr through the raw cache
+func (r *resource[V]) fetch(ctx context.Context, c *Client, addr string) ([]byte, error) {
+	entry, err := r.load(ctx, c, addr)
+	return entry.Val, err
+}
+
+func (r *resource[V]) start(ctx context.Context) {
+	r.raw.Start(ctx)
+	r.decoded.Start(ctx)
+}
+
+func (r *resource[V]) close() {
+	r.raw.Close()
+	r.decoded.Close()
 }
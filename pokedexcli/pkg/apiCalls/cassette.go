@@ -0,0 +1,177 @@
+package apiCalls
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const cassetteSource = "cassette"
+
+// interaction is one request and its response as kept in a cassette. A
+// cassette file holds one interaction per line in the order they happened.
+type interaction struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// Recorder writes every request a client makes and its response to a
+// cassette file. It is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewRecorder: creates the cassette file at path, replacing an existing one.
+// Close the recorder to make sure everything is written.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// WithRecorder: record every request and response to rec. Responses are not
+// written to the disk cache so the cassette sees every request a fresh
+// session would make.
+func WithRecorder(rec *Recorder) ClientOption {
+	return func(c *Client) {
+		c.recorder = rec
+	}
+}
+
+// record: appends an interaction and flushes it so a crashed session keeps
+// everything up to the crash
+func (r *Recorder) record(in interaction) error {
+	line, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+// Close: flushes and closes the cassette file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// recordTransport passes requests on to next and records them
+type recordTransport struct {
+	next http.RoundTripper
+	rec  *Recorder
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	err = t.rec.record(interaction{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not record %s: %w", req.URL, err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// Cassette holds the interactions of a recorded session for replay
+type Cassette struct {
+	mu     sync.Mutex
+	byURL  map[string][]interaction // method and URL to its interactions in recorded order
+	served map[string]int
+}
+
+// LoadCassette: reads a cassette written by a Recorder
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{byURL: map[string][]interaction{}, served: map[string]int{}}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		in := interaction{}
+		if err := json.Unmarshal(line, &in); err != nil {
+			return nil, fmt.Errorf("invalid cassette %s line %d: %w", path, i+1, err)
+		}
+		key := in.Method + " " + in.URL
+		c.byURL[key] = append(c.byURL[key], in)
+	}
+	return c, nil
+}
+
+// WithReplay: answer every request from cas instead of the network. Repeated
+// requests for a URL get its recorded responses in order, the last one is
+// served again once they run out.
+func WithReplay(cas *Cassette) ClientOption {
+	return func(c *Client) {
+		c.cassette = cas
+	}
+}
+
+// next: the interaction to serve for a request
+func (c *Cassette) next(method string, url string) (interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := method + " " + url
+	recorded := c.byURL[key]
+	if len(recorded) == 0 {
+		return interaction{}, false
+	}
+	i := min(c.served[key], len(recorded)-1)
+	c.served[key]++
+	return recorded[i], true
+}
+
+// RoundTrip: serves req from the recorded interactions
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	in, found := c.next(req.Method, req.URL.String())
+	if !found {
+		return nil, &MissingError{Resource: req.Method + " " + req.URL.String(), Source: cassetteSource}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        in.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}, nil
+}
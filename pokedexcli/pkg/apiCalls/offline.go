@@ -0,0 +1,180 @@
+package apiCalls
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// bundleIndexName is the file in a resource directory of a bundle that
+// lists all resources of that kind, in the shape of a PokeAPI list page
+const bundleIndexName = "index.json"
+
+const offlineSource = "offline bundle"
+
+// MissingError is returned in offline and replay mode when the bundle or
+// cassette does not have the requested resource
+type MissingError struct {
+	Resource string // path of the resource relative to the base URL, like pokemon/pikachu
+	Source   string // where the resource was looked for, like offline bundle
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("%s is not in the %s", e.Resource, e.Source)
+}
+
+func (e *MissingError) Unwrap() error {
+	return ErrNotFound
+}
+
+// WithOfflineBundle: answer every request from fsys instead of the network.
+// The bundle holds PokeAPI JSON as <resource>/<name>.json, for example
+// pokemon/pikachu.json, and list pages are cut from <resource>/index.json.
+// Responses are not written to the disk cache.
+func WithOfflineBundle(fsys fs.FS) ClientOption {
+	return func(c *Client) {
+		c.offline = fsys
+	}
+}
+
+// OpenBundle: opens a bundle for WithOfflineBundle from a directory or a zip
+// archive. A bundle wrapped in a single top level directory is opened at
+// that directory. Call close when done with the bundle.
+func OpenBundle(name string) (fsys fs.FS, close func() error, err error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	close = func() error { return nil }
+	if info.IsDir() {
+		fsys = os.DirFS(name)
+	} else {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid offline bundle %s: %w", name, err)
+		}
+		fsys, close = zr, zr.Close
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		close()
+		return nil, nil, fmt.Errorf("invalid offline bundle %s: %w", name, err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		fsys, _ = fs.Sub(fsys, entries[0].Name())
+	}
+	return fsys, close, nil
+}
+
+// offlineTransport is an http.RoundTripper that serves requests below
+// baseURL from a bundle
+type offlineTransport struct {
+	fsys    fs.FS
+	baseURL *url.URL
+}
+
+// newOfflineTransport: an invalid baseURL makes every request miss
+func newOfflineTransport(fsys fs.FS, baseURL string) *offlineTransport {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		u = new(url.URL)
+	}
+	return &offlineTransport{fsys: fsys, baseURL: u}
+}
+
+func (t *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return nil, fmt.Errorf("offline bundle only supports GET, got %s", req.Method)
+	}
+	resource, found := strings.CutPrefix(req.URL.Path, t.baseURL.Path)
+	if !found || req.URL.Host != t.baseURL.Host {
+		return nil, &MissingError{Resource: req.URL.String(), Source: offlineSource}
+	}
+	resource = strings.Trim(resource, "/")
+	if resource == "" || !fs.ValidPath(resource) {
+		return nil, &MissingError{Resource: resource, Source: offlineSource}
+	}
+
+	var body []byte
+	var err error
+	if !strings.Contains(resource, "/") {
+		body, err = t.listPage(resource, req.URL.Query())
+	} else {
+		body, err = fs.ReadFile(t.fsys, resource+".json")
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &MissingError{Resource: resource, Source: offlineSource}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// bundleList is a PokeAPI list page with the results left undecoded
+type bundleList struct {
+	Count    int               `json:"count"`
+	Next     *string           `json:"next"`
+	Previous *string           `json:"previous"`
+	Results  []json.RawMessage `json:"results"`
+}
+
+// listPage: cuts the page selected by the limit and offset query
+// parameters out of the index of resource
+func (t *offlineTransport) listPage(resource string, query url.Values) ([]byte, error) {
+	data, err := fs.ReadFile(t.fsys, path.Join(resource, bundleIndexName))
+	if err != nil {
+		return nil, err
+	}
+	index := bundleList{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid offline bundle index for %s: %w", resource, err)
+	}
+
+	limit, offset := DefaultPageSize, 0
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if n, err := strconv.Atoi(query.Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+	total := len(index.Results)
+	start, end := min(offset, total), min(offset+limit, total)
+
+	pageURL := func(offset int) *string {
+		u := *t.baseURL
+		u.Path = path.Join(u.Path, resource) + "/"
+		u.RawQuery = fmt.Sprintf("offset=%d&limit=%d", offset, limit)
+		s := u.String()
+		return &s
+	}
+	page := bundleList{Count: total, Results: index.Results[start:end]}
+	if end < total {
+		page.Next = pageURL(end)
+	}
+	if start > 0 {
+		page.Previous = pageURL(max(start-limit, 0))
+	}
+	return json.Marshal(page)
+}
@@ -0,0 +1,252 @@
+package apiCalls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/abi01shek/pokedexcli/pkg/atomicfile"
+)
+
+// ErrNoPrefetchTarget is returned by Prefetch when there is neither a disk
+// cache nor a bundle directory to keep the responses in
+var ErrNoPrefetchTarget = errors.New("nowhere to prefetch to: no disk cache and no bundle directory")
+
+// PrefetchProgress describes the resource Prefetch just finished
+type PrefetchProgress struct {
+	Stage string // "pages", "areas", "versions", "pokemon", "species" or "items"
+	Name  string
+	Done  int
+	Total int   // zero while the number of pages is not known yet
+	Had   bool  // already in the cache or bundle from an earlier run
+	Err   error // the resource could not be fetched and is skipped
+}
+
+// PrefetchResult counts what Prefetch did
+type PrefetchResult struct {
+	Areas    int
+	Versions int
+	Pokemon  int
+	Species  int
+	Items    int
+	Had      int // resources that were already prefetched
+	Failed   int
+}
+
+// Prefetch walks every page of the location-area list with pageSize
+// locations per page, the limit ListLocationAreas will be called with, so
+// the cached pages serve it. Then it fetches every area, the game versions
+// their encounters are listed for, every pokemon that can be encountered in
+// one and its species, and finally the given items. Responses go to the
+// disk cache and, when bundleDir is not empty, also into a bundle for
+// WithOfflineBundle. Resources that are already cached or in the bundle are
+// not fetched again, so an interrupted prefetch resumes where it stopped.
+// Resources that fail are reported to progress and skipped, a done ctx
+// stops the walk.
+func (c *Client) Prefetch(ctx context.Context, bundleDir string, pageSize int, progress func(p PrefetchProgress), items ...string) (PrefetchResult, error) {
+	result := PrefetchResult{}
+	if c.cacheDir == "" && bundleDir == "" {
+		return result, ErrNoPrefetchTarget
+	}
+	p := prefetcher{c: c, bundleDir: bundleDir, progress: progress, result: &result}
+
+	index := bundleList{}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	// later pages come from the next links of the API, which keep the limit
+	pageURL := c.locationAreaPageURL(0, pageSize)
+	for page := 1; pageURL != ""; page++ {
+		body, had, err := p.fetch(ctx, c.locationAreaLists, pageURL)
+		if err != nil {
+			return result, fmt.Errorf("location-area list page %d: %w", page, err)
+		}
+		list := bundleList{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return result, fmt.Errorf("location-area list page %d: %w", page, err)
+		}
+		index.Results = append(index.Results, list.Results...)
+		index.Count = list.Count
+		pageURL = ""
+		if list.Next != nil {
+			pageURL = *list.Next
+		}
+		if had {
+			result.Had++
+		}
+		progress(PrefetchProgress{Stage: "pages", Name: fmt.Sprint(page), Done: page, Had: had})
+	}
+	if bundleDir != "" {
+		data, err := json.Marshal(index)
+		if err != nil {
+			return result, err
+		}
+		if err := writeBundleFile(bundleDir, "location-area/"+bundleIndexName, data); err != nil {
+			return result, err
+		}
+	}
+
+	areaNames := []string{}
+	for _, raw := range index.Results {
+		named := struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.Unmarshal(raw, &named); err == nil && named.Name != "" {
+			areaNames = append(areaNames, named.Name)
+		}
+	}
+	seen := map[string]bool{}
+	pokemonNames := []string{}
+	versionNames := []string{}
+	for i, name := range areaNames {
+		area := LocationArea{}
+		had, err := p.resource(ctx, c.locationAreas, "location-area", name, &area)
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		for _, pe := range area.PokemonEncounters {
+			if !seen[pe.Pokemon.Name] {
+				seen[pe.Pokemon.Name] = true
+				pokemonNames = append(pokemonNames, pe.Pokemon.Name)
+			}
+			for _, vd := range pe.VersionDetails {
+				if version := vd.Version.Name; version != "" && !seen["version/"+version] {
+					seen["version/"+version] = true
+					versionNames = append(versionNames, version)
+				}
+			}
+		}
+		if err == nil {
+			result.Areas++
+		}
+		p.report("areas", name, i+1, len(areaNames), had, err)
+	}
+
+	for i, name := range versionNames {
+		version := Version{}
+		had, err := p.resource(ctx, c.versions, "version", name, &version)
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		if err == nil {
+			result.Versions++
+		}
+		p.report("versions", name, i+1, len(versionNames), had, err)
+	}
+
+	sort.Strings(pokemonNames)
+	speciesNames := []string{}
+	for i, name := range pokemonNames {
+		pokemon := Pokemon{}
+		had, err := p.resource(ctx, c.pokemon, "pokemon", name, &pokemon)
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		if err == nil {
+			result.Pokemon++
+			if species := pokemon.Species.Name; species != "" && !seen["species/"+species] {
+				seen["species/"+species] = true
+				speciesNames = append(speciesNames, species)
+			}
+		}
+		p.report("pokemon", name, i+1, len(pokemonNames), had, err)
+	}
+
+	for i, name := range speciesNames {
+		species := PokemonSpecies{}
+		had, err := p.resource(ctx, c.species, "pokemon-species", name, &species)
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		if err == nil {
+			result.Species++
+		}
+		p.report("species", name, i+1, len(speciesNames), had, err)
+	}
+
+	for i, name := range items {
+		item := Item{}
+		had, err := p.resource(ctx, c.items, "item", name, &item)
+		if err != nil && ctx.Err() != nil {
+			return result, err
+		}
+		if err == nil {
+			result.Items++
+		}
+		p.report("items", name, i+1, len(items), had, err)
+	}
+	return result, nil
+}
+
+// prefetcher is the state of a single Prefetch call
+type prefetcher struct {
+	c         *Client
+	bundleDir string
+	progress  func(p PrefetchProgress)
+	result    *PrefetchResult
+}
+
+// resource: fetches kind/name into the cache and the bundle, decodes it
+// into v and reports whether it was prefetched already
+func (p *prefetcher) resource(ctx context.Context, r rawFetcher, kind string, name string, v any) (bool, error) {
+	bundleName := kind + "/" + name + ".json"
+	if p.bundleDir != "" {
+		if data, err := os.ReadFile(filepath.Join(p.bundleDir, filepath.FromSlash(bundleName))); err == nil {
+			if err := json.Unmarshal(data, v); err == nil {
+				return true, nil
+			}
+		}
+	}
+
+	body, had, err := p.fetch(ctx, r, p.c.baseURL+kind+"/"+url.PathEscape(name))
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return false, err
+	}
+	if p.bundleDir != "" {
+		return false, writeBundleFile(p.bundleDir, bundleName, body)
+	}
+	return had, nil
+}
+
+// fetch: gets the raw body at addr and whether it was in the cache already
+func (p *prefetcher) fetch(ctx context.Context, r rawFetcher, addr string) ([]byte, bool, error) {
+	if body, found := r.cached(addr); found {
+		return body, true, nil
+	}
+	body, err := r.fetch(ctx, p.c, addr)
+	return body, false, err
+}
+
+func (p *prefetcher) report(stage string, name string, done int, total int, had bool, err error) {
+	if err != nil {
+		p.result.Failed++
+	} else if had {
+		p.result.Had++
+	}
+	p.progress(PrefetchProgress{Stage: stage, Name: name, Done: done, Total: total, Had: had && err == nil, Err: err})
+}
+
+// rawFetcher is the part of a resource that Prefetch needs, independent of
+// the decoded type
+type rawFetcher interface {
+	cached(addr string) ([]byte, bool)
+	fetch(ctx context.Context, c *Client, addr string) ([]byte, error)
+}
+
+// writeBundleFile: writes data to name in the bundle directory, through a
+// temporary file so an interrupted prefetch never leaves a half written one
+func writeBundleFile(bundleDir string, name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid bundle file name %s", name)
+	}
+	return atomicfile.WriteFile(filepath.Join(bundleDir, filepath.FromSlash(name)), data)
+}
@@ -0,0 +1,94 @@
+package apiCalls
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit and DefaultRateBurst are used by clients created
+// without WithRateLimit
+const DefaultRateLimit = 5.0
+const DefaultRateBurst = 10
+
+// WithRateLimit: send at most rps requests per second on average, with
+// bursts of up to burst requests. Retries count as requests. An rps of zero
+// or less disables the limit.
+func WithRateLimit(rps float64, burst int) ClientOption {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// WithRateLimitWait: call onWait with the delay whenever a request has to
+// wait for the rate limiter, so the caller can tell the user
+func WithRateLimitWait(onWait func(d time.Duration)) ClientOption {
+	return func(c *Client) {
+		c.onRateLimitWait = onWait
+	}
+}
+
+// rateLimiter is a token bucket shared by every request of a client. It is
+// safe for concurrent use.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // most tokens the bucket holds
+	tokens float64 // negative when requests are queued for future tokens
+	last   time.Time
+}
+
+// newRateLimiter: a full bucket, or nil when rps disables the limit
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	l := new(rateLimiter)
+	l.rate = rps
+	l.burst = float64(max(burst, 1))
+	l.tokens = l.burst
+	l.last = time.Now()
+	return l
+}
+
+// reserve: takes a token and returns how long to wait until it is
+// available. Must not be called on a nil limiter.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel: gives back a reserved token that was not used
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.tokens+1, l.burst)
+}
+
+// wait: blocks until the limiter allows another request or ctx is done.
+// A nil limiter never blocks.
+func (c *Client) wait(ctx context.Context) error {
+	if c.limiter == nil {
+		return nil
+	}
+	delay := c.limiter.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	if c.onRateLimitWait != nil {
+		c.onRateLimitWait(delay)
+	}
+	if err := sleepCtx(ctx, delay); err != nil {
+		c.limiter.cancel()
+		return err
+	}
+	return nil
+}
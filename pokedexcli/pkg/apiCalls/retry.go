@@ -0,0 +1,148 @@
+package apiCalls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a network error, a 5xx
+// or a 429 Too Many Requests are retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts including the first one, 1 disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubled for every further retry
+	MaxDelay    time.Duration // upper bound for a single delay, including Retry-After
+	Jitter      float64       // fraction of each delay that is randomized, between 0 and 1
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.5,
+}
+
+// WithRetryPolicy: retry transient failures according to p
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// response is a finished http response with its body read
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// do: sends the request built by newReq, retrying transient failures
+// according to the retry policy of the client
+func (c *Client) do(ctx context.Context, newReq func() (*http.Request, error)) (response, error) {
+	maxAttempts := max(c.retry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return response{}, err
+		}
+		if err := c.wait(ctx); err != nil {
+			return response{}, err
+		}
+		res, err := c.send(req)
+		if err == nil && !retryableStatus(res.statusCode) {
+			c.logf("GET %s: %d after %d attempt(s)", req.URL, res.statusCode, attempt)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return response{}, ctx.Err()
+		}
+		if missing := (*MissingError)(nil); errors.As(err, &missing) {
+			// retrying will not add it to the bundle or cassette
+			return response{}, missing
+		}
+		if err == nil {
+			err = fmt.Errorf("response failed with status code: %d and body: %s", res.statusCode, res.body)
+		}
+		if attempt >= maxAttempts {
+			c.logf("GET %s: giving up after %d attempt(s): %v", req.URL, attempt, err)
+			if attempt > 1 {
+				return response{}, fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return response{}, err
+		}
+
+		delay := c.retry.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(res); ok {
+			delay = min(retryAfter, c.retry.MaxDelay)
+		}
+		c.logf("GET %s: attempt %d/%d failed: %v, retrying in %s", req.URL, attempt, maxAttempts, err, delay.Round(time.Millisecond))
+		if err := sleepCtx(ctx, delay); err != nil {
+			return response{}, err
+		}
+	}
+}
+
+// send: does a single request and reads the whole body
+func (c *Client) send(req *http.Request) (response, error) {
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return response{}, err
+	}
+	return response{statusCode: res.StatusCode, header: res.Header, body: body}, nil
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// backoff: the delay after the given failed attempt, with jitter
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	jitter := min(max(p.Jitter, 0), 1)
+	// spread the delay over [delay*(1-jitter), delay]
+	return delay - time.Duration(rand.Float64()*jitter*float64(delay))
+}
+
+// parseRetryAfter: the delay asked for by a 429 or 503 response, given in
+// seconds or as an HTTP date
+func parseRetryAfter(res response) (time.Duration, bool) {
+	if res.statusCode != http.StatusTooManyRequests && res.statusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	value := res.header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+// Package atomicfile writes files so readers and crashes never see them half
+// written
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile: writes data to a temporary file next to path and renames it
+// over path. Missing directories are created.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package fakeapi
+
+import "fmt"
+
+// DefaultAreaCount is the number of location areas in the default data,
+// enough for three pages of twenty with a partial last page
+const DefaultAreaCount = 45
+
+// defaultVersions are the game versions of the default encounters
+var defaultVersions = []string{"diamond", "pearl"}
+
+// defaultPokemon are the pokemon of the default data with their base
+// experience, height, weight, base hp and capture rate, and how they are
+// encountered in every area they live in
+var defaultPokemon = []struct {
+	name           string
+	baseExperience int
+	height         int
+	weight         int
+	hp             int
+	captureRate    int
+	encounter      Encounter
+}{
+	{"pikachu", 112, 4, 60, 35, 190, Encounter{Method: "walk", Chance: 40, MinLevel: 3, MaxLevel: 6}},
+	{"bulbasaur", 64, 7, 69, 45, 45, Encounter{Versions: []string{"diamond"}, Method: "walk", Chance: 20, MinLevel: 5, MaxLevel: 8}},
+	{"charmander", 62, 6, 85, 39, 45, Encounter{Versions: []string{"pearl"}, Method: "walk", Chance: 20, MinLevel: 5, MaxLevel: 8}},
+	{"squirtle", 63, 5, 90, 44, 45, Encounter{Method: "walk", Chance: 20, MinLevel: 5, MaxLevel: 8}},
+	{"magikarp", 40, 9, 100, 20, 255, Encounter{Method: "old-rod", Chance: 100, MinLevel: 2, MaxLevel: 10}},
+	{"mewtwo", 340, 20, 1220, 106, 3, Encounter{Method: "walk", Chance: 1, MinLevel: 70, MaxLevel: 70}},
+}
+
+// defaultItems are the balls of the default data with their cost
+var defaultItems = []struct {
+	name string
+	cost int
+}{
+	{"poke-ball", 200},
+	{"great-ball", 600},
+	{"ultra-ball", 800},
+	{"master-ball", 0},
+}
+
+// AddDefaultData: adds DefaultAreaCount location areas named fake-area-1
+// and so on, the pokemon found in them with their species, the game
+// versions and the balls.
+// Area n has the pokemon n, n+1 and n+2 of the default pokemon, counting
+// around. Pokemon without versions of their own are found in both
+// defaultVersions.
+func AddDefaultData(h *Handler) {
+	for i, p := range defaultPokemon {
+		h.Set("pokemon", p.name, Pokemon(i+1, p.name, p.baseExperience, p.height, p.weight, p.hp))
+		h.Set("pokemon-species", p.name, PokemonSpecies(i+1, p.name, p.captureRate))
+	}
+	for i, version := range defaultVersions {
+		h.Set("version", version, Version(i+1, version))
+	}
+	for i, item := range defaultItems {
+		h.Set("item", item.name, Item(i+1, item.name, item.cost))
+	}
+	for n := 1; n <= DefaultAreaCount; n++ {
+		encounters := []Encounter{}
+		for i := range 3 {
+			p := defaultPokemon[(n+i)%len(defaultPokemon)]
+			encounter := p.encounter
+			encounter.Pokemon = p.name
+			if len(encounter.Versions) == 0 {
+				encounter.Versions = defaultVersions
+			}
+			encounters = append(encounters, encounter)
+		}
+		name := fmt.Sprintf("fake-area-%d", n)
+		h.Set("location-area", name, LocationArea(n, name, encounters...))
+	}
+}
+
+// Encounter is how a pokemon is met in a location area
+type Encounter struct {
+	Pokemon  string
+	Versions []string // the game versions the pokemon is found in
+	Method   string   // like walk or old-rod
+	Chance   int      // percent of the encounters of Method
+	MinLevel int
+	MaxLevel int
+}
+
+// LocationArea: a location-area document with the given encounters
+func LocationArea(id int, name string, encounters ...Encounter) map[string]any {
+	pokemonEncounters := []any{}
+	for _, e := range encounters {
+		versionDetails := []any{}
+		for _, version := range e.Versions {
+			versionDetails = append(versionDetails, map[string]any{
+				"version":    map[string]any{"name": version, "url": ""},
+				"max_chance": e.Chance,
+				"encounter_details": []any{map[string]any{
+					"chance":           e.Chance,
+					"condition_values": []any{},
+					"min_level":        e.MinLevel,
+					"max_level":        e.MaxLevel,
+					"method":           map[string]any{"name": e.Method, "url": ""},
+				}},
+			})
+		}
+		pokemonEncounters = append(pokemonEncounters, map[string]any{
+			"pokemon":         map[string]any{"name": e.Pokemon, "url": ""},
+			"version_details": versionDetails,
+		})
+	}
+	return map[string]any{
+		"id":                 id,
+		"name":               name,
+		"game_index":         id,
+		"pokemon_encounters": pokemonEncounters,
+	}
+}
+
+// Pokemon: a pokemon document with the fields the pokedex uses, of the
+// species with the same name
+func Pokemon(id int, name string, baseExperience int, height int, weight int, hp int) map[string]any {
+	return map[string]any{
+		"id":              id,
+		"name":            name,
+		"base_experience": baseExperience,
+		"height":          height,
+		"weight":          weight,
+		"species":         map[string]any{"name": name, "url": ""},
+		"stats": []any{map[string]any{
+			"base_stat": hp,
+			"effort":    0,
+			"stat":      map[string]any{"name": "hp", "url": ""},
+		}},
+	}
+}
+
+// PokemonSpecies: a pokemon-species document with its capture rate
+func PokemonSpecies(id int, name string, captureRate int) map[string]any {
+	return map[string]any{
+		"id":           id,
+		"name":         name,
+		"capture_rate": captureRate,
+	}
+}
+
+// Item: an item document with its shop price
+func Item(id int, name string, cost int) map[string]any {
+	return map[string]any{
+		"id":       id,
+		"name":     name,
+		"cost":     cost,
+		"category": map[string]any{"name": "standard-balls", "url": ""},
+	}
+}
+
+// Version: a version document of the diamond-pearl version group
+func Version(id int, name string) map[string]any {
+	return map[string]any{
+		"id":            id,
+		"name":          name,
+		"version_group": map[string]any{"name": "diamond-pearl", "url": ""},
+	}
+}
@@ -0,0 +1,207 @@
+// Package fakeapi is a small in memory stand in for PokeAPI. It serves
+// paginated resource lists, resource details, 404 for unknown resources and
+// 500 on demand, so the client and the REPL can be exercised without the
+// network.
+package fakeapi
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// apiPrefix is the path every resource lives under, like on pokeapi.co
+const apiPrefix = "/api/v2/"
+
+const defaultLimit = 20
+
+// Handler serves PokeAPI resources from memory. It is safe for concurrent
+// use, resources can be changed while it serves.
+type Handler struct {
+	mu        sync.Mutex
+	resources map[string]map[string]any // kind, like pokemon, to name to JSON document
+	order     map[string][]string       // kind to names in list order
+	failures  map[string]int            // path to number of 500 responses left
+	requests  map[string]int            // path to number of requests served
+}
+
+// NewHandler: a handler with the default data, see AddDefaultData
+func NewHandler() *Handler {
+	h := NewEmptyHandler()
+	AddDefaultData(h)
+	return h
+}
+
+// NewEmptyHandler: a handler without any resources
+func NewEmptyHandler() *Handler {
+	h := new(Handler)
+	h.resources = make(map[string]map[string]any)
+	h.order = make(map[string][]string)
+	h.failures = make(map[string]int)
+	h.requests = make(map[string]int)
+	return h
+}
+
+// Set: adds or replaces the resource kind/name. doc is served as JSON. New
+// resources are appended to the list of their kind.
+func (h *Handler) Set(kind string, name string, doc any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.resources[kind] == nil {
+		h.resources[kind] = make(map[string]any)
+	}
+	if _, exists := h.resources[kind][name]; !exists {
+		h.order[kind] = append(h.order[kind], name)
+	}
+	h.resources[kind][name] = doc
+}
+
+// Fail: makes the next n requests for kind/name answer 500. An empty name
+// fails the list of kind.
+func (h *Handler) Fail(kind string, name string, n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[resourcePath(kind, name)] = n
+}
+
+// Requests: how many requests for kind/name were served, failed ones
+// included. An empty name counts the list pages of kind.
+func (h *Handler) Requests(kind string, name string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests[resourcePath(kind, name)]
+}
+
+func resourcePath(kind string, name string) string {
+	if name == "" {
+		return kind
+	}
+	return kind + "/" + name
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path, found := strings.CutPrefix(r.URL.Path, apiPrefix)
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	kind, name, _ := strings.Cut(strings.Trim(path, "/"), "/")
+
+	h.mu.Lock()
+	h.requests[resourcePath(kind, name)]++
+	if h.failures[resourcePath(kind, name)] > 0 {
+		h.failures[resourcePath(kind, name)]--
+		h.mu.Unlock()
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	var doc any
+	if name == "" {
+		doc = h.listPage(r, kind)
+	} else if byName, exists := h.resources[kind]; exists {
+		doc = byName[name]
+		if doc == nil {
+			doc = h.byID(kind, name)
+		}
+	}
+	h.mu.Unlock()
+
+	if doc == nil {
+		// PokeAPI answers unknown resources with a plain text 404
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+// byID: resources can also be looked up by their position in the list,
+// starting at 1. Must be called with mu held.
+func (h *Handler) byID(kind string, name string) any {
+	id, err := strconv.Atoi(name)
+	if err != nil || id < 1 || id > len(h.order[kind]) {
+		return nil
+	}
+	return h.resources[kind][h.order[kind][id-1]]
+}
+
+// listPage: a page of the list of kind in the shape PokeAPI uses, with the
+// next and previous links. Must be called with mu held.
+func (h *Handler) listPage(r *http.Request, kind string) any {
+	names, exists := h.order[kind]
+	if !exists {
+		return nil
+	}
+	limit, offset := defaultLimit, 0
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && n > 0 {
+		offset = n
+	}
+	start, end := min(offset, len(names)), min(offset+limit, len(names))
+
+	base := "http://" + r.Host + apiPrefix + kind + "/"
+	pageURL := func(offset int) *string {
+		link := fmt.Sprintf("%s?offset=%d&limit=%d", base, offset, limit)
+		return &link
+	}
+	type namedResource struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	page := struct {
+		Count    int             `json:"count"`
+		Next     *string         `json:"next"`
+		Previous *string         `json:"previous"`
+		Results  []namedResource `json:"results"`
+	}{Count: len(names), Results: []namedResource{}}
+	for i, name := range names[start:end] {
+		page.Results = append(page.Results, namedResource{Name: name, URL: base + strconv.Itoa(start+i+1) + "/"})
+	}
+	if end < len(names) {
+		page.Next = pageURL(end)
+	}
+	if start > 0 {
+		page.Previous = pageURL(max(start-limit, 0))
+	}
+	return page
+}
+
+// Server is a running fake PokeAPI for tests
+type Server struct {
+	*httptest.Server
+	*Handler
+}
+
+// NewServer: starts a fake PokeAPI with the default data on a local port.
+// Close it when done.
+func NewServer() *Server {
+	h := NewHandler()
+	return &Server{Server: httptest.NewServer(h), Handler: h}
+}
+
+// BaseURL: the address to point the client at, like apiCalls.DefaultBaseURL
+func (s *Server) BaseURL() string {
+	return s.URL + apiPrefix
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ballT is a kind of ball to catch pokemon with. Names match the PokeAPI
+// item names.
+type ballT struct {
+	name     string
+	title    string
+	modifier float64 // catch rate modifier, see catchAttempt
+	always   bool    // catches every pokemon
+}
+
+// balls in the order the bag lists them
+var balls = []ballT{
+	{name: "poke-ball", title: "Poke Ball", modifier: 1},
+	{name: "great-ball", title: "Great Ball", modifier: 1.5},
+	{name: "ultra-ball", title: "Ultra Ball", modifier: 2},
+	{name: "master-ball", title: "Master Ball", modifier: 255, always: true},
+}
+
+const defaultBall = "poke-ball"
+
+// starterBag: the balls a new trainer starts with
+func starterBag() map[string]int {
+	return map[string]int{
+		"poke-ball":  10,
+		"great-ball": 3,
+	}
+}
+
+// findBall: the ball called name, where the -ball suffix can be left out
+func findBall(name string) (ballT, bool) {
+	name = strings.TrimSuffix(name, "-ball") + "-ball"
+	for _, ball := range balls {
+		if ball.name == name {
+			return ball, true
+		}
+	}
+	return ballT{}, false
+}
+
+// commandBag: list the balls and money the trainer carries
+func commandBag(ctx context.Context, cfg *config, args ...string) error {
+	fmt.Printf("Your Bag:\n")
+	for _, ball := range balls {
+		fmt.Printf("\t- %s: %d\n", ball.title, cfg.bag[ball.name])
+	}
+	fmt.Printf("Money: %s\n", formatMoney(cfg.money))
+	return nil
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// defaultWildLevel is the level of pokemon caught before encounters had levels
+const defaultWildLevel = 5
+
+// statusCondition is a non volatile status a pokemon can have, it makes
+// the pokemon easier to catch
+type statusCondition string
+
+const (
+	statusNone      statusCondition = ""
+	statusSleep     statusCondition = "sleep"
+	statusFreeze    statusCondition = "freeze"
+	statusParalysis statusCondition = "paralysis"
+	statusPoison    statusCondition = "poison"
+	statusBurn      statusCondition = "burn"
+)
+
+// modifier: the catch rate bonus of the status
+func (s statusCondition) modifier() float64 {
+	switch s {
+	case statusSleep, statusFreeze:
+		return 2
+	case statusParalysis, statusPoison, statusBurn:
+		return 1.5
+	}
+	return 1
+}
+
+func (s statusCondition) String() string {
+	if s == statusNone {
+		return "no status"
+	}
+	return string(s)
+}
+
+// catchAttempt is everything the capture formula looks at
+type catchAttempt struct {
+	captureRate int // of the species, 3 for legendaries up to 255
+	maxHP       int
+	hp          int
+	ball        ballT
+	status      statusCondition
+}
+
+// shakeThreshold: the modified catch rate a and the threshold b each of
+// the four shake checks has to roll under, like the games since Gen III.
+// An a of 255 or more is a guaranteed catch.
+func (c catchAttempt) shakeThreshold() (a float64, b float64) {
+	if c.maxHP <= 0 {
+		return 0, 0
+	}
+	a = float64(3*c.maxHP-2*c.hp) * float64(c.captureRate) * c.ball.modifier / float64(3*c.maxHP) * c.status.modifier()
+	if a <= 0 && !c.ball.always {
+		return 0, 0
+	}
+	if a >= 255 || c.ball.always {
+		return a, 65536
+	}
+	return a, math.Floor(1048560 / math.Sqrt(math.Sqrt(16711680/a)))
+}
+
+// chance: the probability that the attempt succeeds
+func (c catchAttempt) chance() float64 {
+	_, b := c.shakeThreshold()
+	return math.Pow(min(b/65536, 1), 4)
+}
+
+// throw: rolls the four shake checks, a catch needs all of them
+func (c catchAttempt) throw() (caught bool, shakes int) {
+	_, b := c.shakeThreshold()
+	for shakes = 0; shakes < 4; shakes++ {
+		if float64(rand.Intn(65536)) >= b {
+			return false, shakes
+		}
+	}
+	return true, shakes
+}
+
+// maxHP: the hp of a pokemon at level, from its base hp stat
+func maxHP(pokemon pokemonT, level int) int {
+	for _, stat := range pokemon.Stats {
+		if stat.Stat.Name == "hp" {
+			return 2*stat.BaseStat*level/100 + level + 10
+		}
+	}
+	return level + 10
+}
+
+// commandCatch: try to catch the wild pokemon of the current encounter,
+// using up a ball
+func commandCatch(ctx context.Context, cfg *config, args ...string) error {
+	pokemonName, ballName := "", defaultBall
+	switch len(args) {
+	case 0:
+	case 1:
+		if _, isBall := findBall(args[0]); isBall {
+			ballName = args[0]
+		} else {
+			pokemonName = args[0]
+		}
+	case 2:
+		pokemonName, ballName = args[0], args[1]
+	default:
+		return errors.New("usage: catch [pokemon] [poke|great|ultra|master]")
+	}
+	ball, found := findBall(ballName)
+	if !found {
+		return fmt.Errorf("unknown ball %s", ballName)
+	}
+	if cfg.encounter == nil {
+		return errors.New("there is no wild pokemon to catch, use encounter to find one")
+	}
+	if pokemonName == "" {
+		pokemonName = cfg.encounter.name
+	}
+	if pokemonName != cfg.encounter.name {
+		return fmt.Errorf("the wild pokemon here is %s, not %s", cfg.encounter.name, pokemonName)
+	}
+	if cfg.bag[ball.name] <= 0 {
+		return fmt.Errorf("you have no %ss left, check your bag", ball.title)
+	}
+
+	pokemonRes, err := cfg.client.GetPokemon(ctx, pokemonName)
+	if err != nil {
+		return err
+	}
+	speciesName := pokemonRes.Species.Name
+	if speciesName == "" {
+		speciesName = pokemonName
+	}
+	species, err := cfg.client.GetPokemonSpecies(ctx, speciesName)
+	if err != nil {
+		return err
+	}
+
+	// wild pokemon are met at full health
+	level := cfg.encounter.level
+	hp := maxHP(pokemonRes, level)
+	attempt := catchAttempt{
+		captureRate: species.CaptureRate,
+		maxHP:       hp,
+		hp:          hp,
+		ball:        ball,
+		status:      statusNone,
+	}
+
+	cfg.bag[ball.name]--
+	fmt.Printf("Throwing a %s at %s...\n", ball.title, pokemonName)
+	if cfg.verbose.Load() {
+		fmt.Printf("Catch chance %.1f%% (capture rate %d, hp %d/%d, %s x%g, %s)\n",
+			attempt.chance()*100, attempt.captureRate, attempt.hp, attempt.maxHP, ball.title, ball.modifier, attempt.status)
+	}
+	caught, shakes := attempt.throw()
+	if !caught {
+		if cfg.verbose.Load() {
+			fmt.Printf("The ball shook %d time(s)\n", shakes)
+		}
+		fmt.Printf("%s escaped!\n", pokemonName)
+		cfg.stats.Escaped++
+		return nil
+	}
+
+	fmt.Printf("%s was caught!\n", pokemonName)
+	cfg.stats.Caught++
+	cfg.caughtPokemon[pokemonName] = caughtPokemonT{pokemonT: pokemonRes, Level: level}
+	cfg.encounter = nil
+	return nil
+}
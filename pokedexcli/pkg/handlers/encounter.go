@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// defaultEncounterMethod is how wild pokemon are met unless the trainer
+// asks for another way, like surf or old-rod
+const defaultEncounterMethod = "walk"
+
+// encounterSlot is one way a pokemon can appear in an area
+type encounterSlot struct {
+	pokemon  string
+	version  string
+	method   string
+	chance   int // weight of the slot among the slots of its version and method
+	minLevel int
+	maxLevel int
+}
+
+// wildEncounter is the wild pokemon the trainer is facing
+type wildEncounter struct {
+	name   string
+	level  int
+	method string
+}
+
+// encounterSlots: every encounter slot of an area
+func encounterSlots(area exploreAreaT) []encounterSlot {
+	slots := []encounterSlot{}
+	for _, pe := range area.PokemonEncounters {
+		for _, vd := range pe.VersionDetails {
+			for _, ed := range vd.EncounterDetails {
+				slots = append(slots, encounterSlot{
+					pokemon:  pe.Pokemon.Name,
+					version:  vd.Version.Name,
+					method:   ed.Method.Name,
+					chance:   ed.Chance,
+					minLevel: ed.MinLevel,
+					maxLevel: ed.MaxLevel,
+				})
+			}
+		}
+	}
+	return slots
+}
+
+// encounterVersion: the game version to encounter pokemon of. Without a
+// selected version it is the version of the first slot so chances of
+// different games are not mixed.
+func encounterVersion(slots []encounterSlot, selected string) string {
+	if selected == "" && len(slots) > 0 {
+		return slots[0].version
+	}
+	return selected
+}
+
+// filterSlots: the slots of version and method
+func filterSlots(slots []encounterSlot, version string, method string) []encounterSlot {
+	filtered := []encounterSlot{}
+	for _, slot := range slots {
+		if slot.version == version && slot.method == method {
+			filtered = append(filtered, slot)
+		}
+	}
+	return filtered
+}
+
+// encounterMethods: the methods that have slots in version
+func encounterMethods(slots []encounterSlot, version string) []string {
+	seen := map[string]bool{}
+	methods := []string{}
+	for _, slot := range slots {
+		if slot.version == version && !seen[slot.method] {
+			seen[slot.method] = true
+			methods = append(methods, slot.method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// pickEncounter: picks a slot weighted by its chance and a level in its range
+func pickEncounter(slots []encounterSlot) (wildEncounter, bool) {
+	total := 0
+	for _, slot := range slots {
+		total += max(slot.chance, 0)
+	}
+	if total == 0 {
+		return wildEncounter{}, false
+	}
+	roll := rand.Intn(total)
+	for _, slot := range slots {
+		roll -= max(slot.chance, 0)
+		if roll < 0 {
+			level := slot.minLevel
+			if slot.maxLevel > slot.minLevel {
+				level += rand.Intn(slot.maxLevel - slot.minLevel + 1)
+			}
+			return wildEncounter{name: slot.pokemon, level: max(level, 1), method: slot.method}, true
+		}
+	}
+	return wildEncounter{}, false
+}
+
+// encounterChance: the share of encounters with slots that are pokemon
+func encounterChance(slots []encounterSlot, pokemon string) float64 {
+	total, matching := 0, 0
+	for _, slot := range slots {
+		total += max(slot.chance, 0)
+		if slot.pokemon == pokemon {
+			matching += max(slot.chance, 0)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matching) / float64(total)
+}
+
+// commandEncounter: look for a wild pokemon in the current area
+func commandEncounter(ctx context.Context, cfg *config, args ...string) error {
+	if len(args) > 1 {
+		return errors.New("usage: encounter [method]")
+	}
+	method := defaultEncounterMethod
+	if len(args) == 1 {
+		method = args[0]
+	}
+	if cfg.currentArea == "" {
+		return errors.New("explore an area first")
+	}
+
+	area, err := cfg.client.GetLocationArea(ctx, cfg.currentArea)
+	if err != nil {
+		return err
+	}
+	allSlots := encounterSlots(area)
+	version := encounterVersion(allSlots, cfg.version)
+	slots := filterSlots(allSlots, version, method)
+	encounter, found := pickEncounter(slots)
+	if !found {
+		methods := encounterMethods(allSlots, version)
+		if len(methods) == 0 && cfg.version != "" {
+			return fmt.Errorf("there are no wild pokemon of %s in %s", cfg.version, cfg.currentArea)
+		}
+		if len(methods) == 0 {
+			return fmt.Errorf("there are no wild pokemon in %s", cfg.currentArea)
+		}
+		return fmt.Errorf("no pokemon can be found by %s in %s, try %s", method, cfg.currentArea, strings.Join(methods, ", "))
+	}
+
+	cfg.encounter = &encounter
+	fmt.Printf("A wild %s appeared! (level %d)\n", encounter.name, encounter.level)
+	if cfg.verbose.Load() {
+		fmt.Printf("%s shows up in %.1f%% of %s encounters here in %s\n",
+			encounter.name, encounterChance(slots, encounter.name)*100, method, version)
+	}
+	return nil
+}
@@ -2,372 +2,73 @@ package handlers
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
-	"github.com/abi01shek/pokedexcli/pkg/pokecache"
 )
 
-const defatulApiAddress = "https://pokeapi.co/api/v2/location-area/?limit=20&offset=20"
-const exploreBaseAddress = "https://pokeapi.co/api/v2/location-area/"
-const pokemonBaseAddress = "https://pokeapi.co/api/v2/pokemon/"
+const cacheDirName = "cache"
 
 type cliCommand struct {
 	name        string
 	description string
-	callback    func(cfg *config, args ...string) error
+	callback    func(ctx context.Context, cfg *config, args ...string) error
 }
 
-type locationApiResT struct {
-	Count    int     `json:"count"`
-	Next     *string `json:"next"`
-	Previous *string `json:"previous"`
-	Results  []struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"results"`
-}
+type locationApiResT = apiCalls.LocationAreaList
+type exploreAreaT = apiCalls.LocationArea
+type pokemonT = apiCalls.Pokemon
 
-type exploreAreaT struct {
-	EncounterMethodRates []struct {
-		EncounterMethod struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"encounter_method"`
-		VersionDetails []struct {
-			Rate    int `json:"rate"`
-			Version struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-		} `json:"version_details"`
-	} `json:"encounter_method_rates"`
-	GameIndex int `json:"game_index"`
-	ID        int `json:"id"`
-	Location  struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"location"`
-	Name  string `json:"name"`
-	Names []struct {
-		Language struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"language"`
-		Name string `json:"name"`
-	} `json:"names"`
-	PokemonEncounters []struct {
-		Pokemon struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"pokemon"`
-		VersionDetails []struct {
-			EncounterDetails []struct {
-				Chance          int   `json:"chance"`
-				ConditionValues []any `json:"condition_values"`
-				MaxLevel        int   `json:"max_level"`
-				Method          struct {
-					Name string `json:"name"`
-					URL  string `json:"url"`
-				} `json:"method"`
-				MinLevel int `json:"min_level"`
-			} `json:"encounter_details"`
-			MaxChance int `json:"max_chance"`
-			Version   struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-		} `json:"version_details"`
-	} `json:"pokemon_encounters"`
+// caughtPokemonT is a pokemon in the pokedex with the level it was caught at
+type caughtPokemonT struct {
+	pokemonT
+	Level int `json:"level"`
 }
 
-type pokemonT struct {
-	Abilities []struct {
-		Ability struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"ability"`
-		IsHidden bool `json:"is_hidden"`
-		Slot     int  `json:"slot"`
-	} `json:"abilities"`
-	BaseExperience int `json:"base_experience"`
-	Cries          struct {
-		Latest string `json:"latest"`
-		Legacy string `json:"legacy"`
-	} `json:"cries"`
-	Forms []struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"forms"`
-	GameIndices []struct {
-		GameIndex int `json:"game_index"`
-		Version   struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"version"`
-	} `json:"game_indices"`
-	Height    int `json:"height"`
-	HeldItems []struct {
-		Item struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"item"`
-		VersionDetails []struct {
-			Rarity  int `json:"rarity"`
-			Version struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version"`
-		} `json:"version_details"`
-	} `json:"held_items"`
-	ID                     int    `json:"id"`
-	IsDefault              bool   `json:"is_default"`
-	LocationAreaEncounters string `json:"location_area_encounters"`
-	Moves                  []struct {
-		Move struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"move"`
-		VersionGroupDetails []struct {
-			LevelLearnedAt  int `json:"level_learned_at"`
-			MoveLearnMethod struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"move_learn_method"`
-			VersionGroup struct {
-				Name string `json:"name"`
-				URL  string `json:"url"`
-			} `json:"version_group"`
-		} `json:"version_group_details"`
-	} `json:"moves"`
-	Name          string `json:"name"`
-	Order         int    `json:"order"`
-	PastAbilities []any  `json:"past_abilities"`
-	PastTypes     []any  `json:"past_types"`
-	Species       struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
-	} `json:"species"`
-	Sprites struct {
-		BackDefault      string `json:"back_default"`
-		BackFemale       string `json:"back_female"`
-		BackShiny        string `json:"back_shiny"`
-		BackShinyFemale  string `json:"back_shiny_female"`
-		FrontDefault     string `json:"front_default"`
-		FrontFemale      string `json:"front_female"`
-		FrontShiny       string `json:"front_shiny"`
-		FrontShinyFemale string `json:"front_shiny_female"`
-		Other            struct {
-			DreamWorld struct {
-				FrontDefault string `json:"front_default"`
-				FrontFemale  any    `json:"front_female"`
-			} `json:"dream_world"`
-			Home struct {
-				FrontDefault     string `json:"front_default"`
-				FrontFemale      string `json:"front_female"`
-				FrontShiny       string `json:"front_shiny"`
-				FrontShinyFemale string `json:"front_shiny_female"`
-			} `json:"home"`
-			OfficialArtwork struct {
-				FrontDefault string `json:"front_default"`
-				FrontShiny   string `json:"front_shiny"`
-			} `json:"official-artwork"`
-			Showdown struct {
-				BackDefault      string `json:"back_default"`
-				BackFemale       string `json:"back_female"`
-				BackShiny        string `json:"back_shiny"`
-				BackShinyFemale  any    `json:"back_shiny_female"`
-				FrontDefault     string `json:"front_default"`
-				FrontFemale      string `json:"front_female"`
-				FrontShiny       string `json:"front_shiny"`
-				FrontShinyFemale string `json:"front_shiny_female"`
-			} `json:"showdown"`
-		} `json:"other"`
-		Versions struct {
-			GenerationI struct {
-				RedBlue struct {
-					BackDefault      string `json:"back_default"`
-					BackGray         string `json:"back_gray"`
-					BackTransparent  string `json:"back_transparent"`
-					FrontDefault     string `json:"front_default"`
-					FrontGray        string `json:"front_gray"`
-					FrontTransparent string `json:"front_transparent"`
-				} `json:"red-blue"`
-				Yellow struct {
-					BackDefault      string `json:"back_default"`
-					BackGray         string `json:"back_gray"`
-					BackTransparent  string `json:"back_transparent"`
-					FrontDefault     string `json:"front_default"`
-					FrontGray        string `json:"front_gray"`
-					FrontTransparent string `json:"front_transparent"`
-				} `json:"yellow"`
-			} `json:"generation-i"`
-			GenerationIi struct {
-				Crystal struct {
-					BackDefault           string `json:"back_default"`
-					BackShiny             string `json:"back_shiny"`
-					BackShinyTransparent  string `json:"back_shiny_transparent"`
-					BackTransparent       string `json:"back_transparent"`
-					FrontDefault          string `json:"front_default"`
-					FrontShiny            string `json:"front_shiny"`
-					FrontShinyTransparent string `json:"front_shiny_transparent"`
-					FrontTransparent      string `json:"front_transparent"`
-				} `json:"crystal"`
-				Gold struct {
-					BackDefault      string `json:"back_default"`
-					BackShiny        string `json:"back_shiny"`
-					FrontDefault     string `json:"front_default"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontTransparent string `json:"front_transparent"`
-				} `json:"gold"`
-				Silver struct {
-					BackDefault      string `json:"back_default"`
-					BackShiny        string `json:"back_shiny"`
-					FrontDefault     string `json:"front_default"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontTransparent string `json:"front_transparent"`
-				} `json:"silver"`
-			} `json:"generation-ii"`
-			GenerationIii struct {
-				Emerald struct {
-					FrontDefault string `json:"front_default"`
-					FrontShiny   string `json:"front_shiny"`
-				} `json:"emerald"`
-				FireredLeafgreen struct {
-					BackDefault  string `json:"back_default"`
-					BackShiny    string `json:"back_shiny"`
-					FrontDefault string `json:"front_default"`
-					FrontShiny   string `json:"front_shiny"`
-				} `json:"firered-leafgreen"`
-				RubySapphire struct {
-					BackDefault  string `json:"back_default"`
-					BackShiny    string `json:"back_shiny"`
-					FrontDefault string `json:"front_default"`
-					FrontShiny   string `json:"front_shiny"`
-				} `json:"ruby-sapphire"`
-			} `json:"generation-iii"`
-			GenerationIv struct {
-				DiamondPearl struct {
-					BackDefault      string `json:"back_default"`
-					BackFemale       string `json:"back_female"`
-					BackShiny        string `json:"back_shiny"`
-					BackShinyFemale  string `json:"back_shiny_female"`
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"diamond-pearl"`
-				HeartgoldSoulsilver struct {
-					BackDefault      string `json:"back_default"`
-					BackFemale       string `json:"back_female"`
-					BackShiny        string `json:"back_shiny"`
-					BackShinyFemale  string `json:"back_shiny_female"`
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"heartgold-soulsilver"`
-				Platinum struct {
-					BackDefault      string `json:"back_default"`
-					BackFemale       string `json:"back_female"`
-					BackShiny        string `json:"back_shiny"`
-					BackShinyFemale  string `json:"back_shiny_female"`
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"platinum"`
-			} `json:"generation-iv"`
-			GenerationV struct {
-				BlackWhite struct {
-					Animated struct {
-						BackDefault      string `json:"back_default"`
-						BackFemale       string `json:"back_female"`
-						BackShiny        string `json:"back_shiny"`
-						BackShinyFemale  string `json:"back_shiny_female"`
-						FrontDefault     string `json:"front_default"`
-						FrontFemale      string `json:"front_female"`
-						FrontShiny       string `json:"front_shiny"`
-						FrontShinyFemale string `json:"front_shiny_female"`
-					} `json:"animated"`
-					BackDefault      string `json:"back_default"`
-					BackFemale       string `json:"back_female"`
-					BackShiny        string `json:"back_shiny"`
-					BackShinyFemale  string `json:"back_shiny_female"`
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"black-white"`
-			} `json:"generation-v"`
-			GenerationVi struct {
-				OmegarubyAlphasapphire struct {
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"omegaruby-alphasapphire"`
-				XY struct {
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"x-y"`
-			} `json:"generation-vi"`
-			GenerationVii struct {
-				Icons struct {
-					FrontDefault string `json:"front_default"`
-					FrontFemale  any    `json:"front_female"`
-				} `json:"icons"`
-				UltraSunUltraMoon struct {
-					FrontDefault     string `json:"front_default"`
-					FrontFemale      string `json:"front_female"`
-					FrontShiny       string `json:"front_shiny"`
-					FrontShinyFemale string `json:"front_shiny_female"`
-				} `json:"ultra-sun-ultra-moon"`
-			} `json:"generation-vii"`
-			GenerationViii struct {
-				Icons struct {
-					FrontDefault string `json:"front_default"`
-					FrontFemale  string `json:"front_female"`
-				} `json:"icons"`
-			} `json:"generation-viii"`
-		} `json:"versions"`
-	} `json:"sprites"`
-	Stats []struct {
-		BaseStat int `json:"base_stat"`
-		Effort   int `json:"effort"`
-		Stat     struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"stat"`
-	} `json:"stats"`
-	Types []struct {
-		Slot int `json:"slot"`
-		Type struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
-		} `json:"type"`
-	} `json:"types"`
-	Weight int `json:"weight"`
+type config struct {
+	*profile
+	client   *apiCalls.Client
+	dataDir  string
+	autosave bool
+	pageSize int    // locations per map page
+	version  string // game version explore and encounter show, empty for all
+	// verbose and prefetching are also read by the API client callbacks,
+	// which run from background cache refreshes
+	verbose atomic.Bool
+	// a running prefetch shows its own status line instead of rate limit notices
+	prefetching atomic.Bool
 }
 
-type config struct {
-	locationPrev        string
-	locationNext        string
-	locationCache       *pokecache.Cache
-	exploreCache        *pokecache.Cache
-	pokemonInCurrentLoc map[string]bool
-	caughtPokemon       map[string]pokemonT
+// Options are the settings StartRepl takes from the command line
+type Options struct {
+	BaseURL   string               // PokeAPI address, empty to use the environment or config file
+	PageSize  int                  // locations per map page, zero to use the config file
+	Offline   string               // directory or zip of PokeAPI JSON to play from without network
+	Record    string               // cassette file to record every API request and response to
+	Replay    string               // cassette file to replay a recorded session from without network
+	Verbose   bool                 // print every API request and retry
+	Version   string               // game version to show pokemon of, like diamond, empty for all
+	Retry     apiCalls.RetryPolicy // how failed API requests are retried
+	RateLimit float64              // API requests per second, zero for no limit
+	RateBurst int                  // API requests allowed at once before the limit applies
+
+	// Prefetch downloads everything into the response cache and returns
+	// instead of starting the REPL. PrefetchBundle also writes it to that
+	// directory for offline play.
+	Prefetch       bool
+	PrefetchBundle string
 }
 
-func commandHelp(cfg *config, args ...string) error {
+func commandHelp(ctx context.Context, cfg *config, args ...string) error {
 	fmt.Printf("Welcome to Pokedex!\nUsage: \n")
 	for _, cmd := range getCommand() {
 		fmt.Printf("%s: %s\n", cmd.name, cmd.description)
@@ -376,170 +77,136 @@ func commandHelp(cfg *config, args ...string) error {
 	return nil
 }
 
-func commandExit(cfg *config, args ...string) error {
-	os.Exit(0)
-	return nil
+// errExit is returned by commandExit to make the REPL shut down
+var errExit = errors.New("exit")
+
+func commandExit(ctx context.Context, cfg *config, args ...string) error {
+	return errExit
 }
 
-// getLocation: Gets location data for a given address either from
-// the cache or through API
-func getLocation(cfg *config, addr string) ([]byte, error) {
-	var body []byte
-	var err error
-	body, found := cfg.locationCache.Get(addr)
-	if !found {
-		body, err = apiCalls.GetBodyApiCall(addr)
-		if err != nil {
-			return nil, err
-		}
-		cfg.locationCache.Add(addr, body)
+// printStaleNotice: tells the user the output may be outdated
+func printStaleNotice(stale bool) {
+	if stale {
+		fmt.Printf("(showing expired cached data, refreshing in the background)\n")
 	}
-	return body, nil
 }
 
-// exploreLocation: Gets the explore location data for given address
-// either from cache or through API
-func exploreLocation(cfg *config, addr string) ([]byte, error) {
-	var body []byte
-	var err error
-	body, found := cfg.exploreCache.Get(addr)
-	if !found {
-		body, err = apiCalls.GetBodyApiCall(addr)
+// errNoSuchPage is returned by showMapPage for a page past the last one
+var errNoSuchPage = errors.New("no page")
+
+// commandMap: show the next page of locations, or the first, last or a
+// numbered page
+func commandMap(ctx context.Context, cfg *config, args ...string) error {
+	offset := 0
+	switch {
+	case len(args) == 0:
+		if cfg.mapOffset >= 0 {
+			offset = cfg.mapOffset + cfg.pageSize
+		}
+		err := showMapPage(ctx, cfg, offset)
+		if errors.Is(err, errNoSuchPage) {
+			return errors.New("you are on the last page, use map first to start over")
+		}
+		return err
+	case len(args) == 1 && args[0] == "first":
+	case len(args) == 1 && args[0] == "last":
+		firstPage, err := cfg.client.ListLocationAreas(ctx, 0, cfg.pageSize)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		cfg.exploreCache.Add(addr, body)
+		offset = max(firstPage.Count-1, 0) / cfg.pageSize * cfg.pageSize
+	case len(args) == 2 && args[0] == "page":
+		page, err := strconv.Atoi(args[1])
+		if err != nil || page < 1 {
+			return fmt.Errorf("invalid page %s, pages start at 1", args[1])
+		}
+		offset = (page - 1) * cfg.pageSize
+	default:
+		return errors.New("usage: map [first|last|page <n>]")
 	}
-	return body, nil
+	return showMapPage(ctx, cfg, offset)
 }
 
-// commandMap: Get the next 20 locations
-func commandMap(cfg *config, args ...string) error {
-	nextLocAddr := cfg.locationNext
-	if nextLocAddr == "" {
-		nextLocAddr = defatulApiAddress
-	}
-
-	body, err := getLocation(cfg, nextLocAddr)
-	if err != nil {
-		return err
+// commandMapb : show the previous page of locations
+func commandMapb(ctx context.Context, cfg *config, args ...string) error {
+	if cfg.mapOffset <= 0 {
+		return errors.New("you are on the first page")
 	}
-
-	locRes := locationApiResT{}
-	err = json.Unmarshal(body, &locRes)
-	if err != nil {
-		return err
-	}
-
-	cfg.locationNext = *locRes.Next
-	cfg.locationPrev = *locRes.Previous
-
-	for _, myLoc := range locRes.Results {
-		fmt.Printf("%s\n", myLoc.Name)
-	}
-	return nil
+	return showMapPage(ctx, cfg, max(cfg.mapOffset-cfg.pageSize, 0))
 }
 
-// commandMapb : get previous 20 locations
-func commandMapb(cfg *config, args ...string) error {
-	prevLocAddr := cfg.locationPrev
-	if prevLocAddr == "" {
-		return errors.New("no previous locations found")
-	}
-
-	body, err := getLocation(cfg, prevLocAddr)
-	if err != nil {
-		return err
-	}
-
-	locRes := locationApiResT{}
-	err = json.Unmarshal(body, &locRes)
+// showMapPage: prints the page of locations starting at offset and makes
+// it the current page
+func showMapPage(ctx context.Context, cfg *config, offset int) error {
+	locRes, err := cfg.client.ListLocationAreas(ctx, offset, cfg.pageSize)
 	if err != nil {
 		return err
 	}
-
-	if locRes.Next != nil {
-		cfg.locationNext = *locRes.Next
-	} else {
-		cfg.locationNext = ""
-	}
-
-	if locRes.Previous != nil {
-		cfg.locationPrev = *locRes.Previous
-	} else {
-		cfg.locationPrev = ""
+	pages := (locRes.Count + cfg.pageSize - 1) / cfg.pageSize
+	if len(locRes.Results) == 0 {
+		if locRes.Count == 0 {
+			return errors.New("no locations found")
+		}
+		return fmt.Errorf("%w %d, there are %d pages", errNoSuchPage, offset/cfg.pageSize+1, pages)
 	}
 
+	cfg.mapOffset = offset
 	for _, myLoc := range locRes.Results {
 		fmt.Printf("%s\n", myLoc.Name)
 	}
+	fmt.Printf("Page %d of %d\n", offset/cfg.pageSize+1, pages)
+	printStaleNotice(locRes.Stale)
 	return nil
-
 }
 
-func commandExplore(cfg *config, args ...string) error {
-	expLoc := strings.Join(args[:], "")
-	apiAddr := exploreBaseAddress + expLoc
+func commandExplore(ctx context.Context, cfg *config, args ...string) error {
+	if len(args) != 1 {
+		return errors.New("usage: explore <area>")
+	}
+	expLoc := args[0]
 	fmt.Printf("Exploring %s ...\n", expLoc)
 
-	body, err := exploreLocation(cfg, apiAddr)
+	exploreRes, err := cfg.client.GetLocationArea(ctx, expLoc)
 	if err != nil {
 		return err
 	}
 
-	exploreRes := exploreAreaT{}
-	err = json.Unmarshal(body, &exploreRes)
-	if err != nil {
-		return err
+	cfg.currentArea = expLoc
+	cfg.encounter = nil
+	cfg.stats.Explored++
+	if !cfg.exploredAreas[expLoc] {
+		cfg.exploredAreas[expLoc] = true
+		cfg.money += newAreaReward
+		fmt.Printf("You found %s exploring a new area!\n", formatMoney(newAreaReward))
 	}
-
-	cfg.pokemonInCurrentLoc = make(map[string]bool)
+	versions := pokemonVersions(exploreRes)
+	shown := 0
 	fmt.Printf("Found Pokemon:\n")
 	for _, pe := range exploreRes.PokemonEncounters {
-		fmt.Printf("\t- %s\n", pe.Pokemon.Name)
-		cfg.pokemonInCurrentLoc[pe.Pokemon.Name] = true
-	}
-
-	return nil
-}
-
-// commandCatch: try to catch a pokemon
-func commandCatch(cfg *config, args ...string) error {
-	pokemonName := strings.Join(args[:], "")
-	if _, exists := cfg.pokemonInCurrentLoc[pokemonName]; !exists {
-		fmt.Printf("Pokemon %s not found in current location\n", pokemonName)
-		return nil
-	}
-
-	apiAddr := pokemonBaseAddress + pokemonName
-	body, err := apiCalls.GetBodyApiCall(apiAddr)
-	if err != nil {
-		return nil
-	}
-
-	pokemonRes := pokemonT{}
-	err = json.Unmarshal(body, &pokemonRes)
-	if err != nil {
-		return err
+		if !inVersion(versions[pe.Pokemon.Name], cfg.version) {
+			continue
+		}
+		if len(versions[pe.Pokemon.Name]) > 0 {
+			fmt.Printf("\t- %s (%s)\n", pe.Pokemon.Name, strings.Join(versions[pe.Pokemon.Name], ", "))
+		} else {
+			fmt.Printf("\t- %s\n", pe.Pokemon.Name)
+		}
+		shown++
 	}
-
-	fmt.Printf("Throwing a Pokeball at %s...\n", pokemonName)
-	rval := rand.Intn(pokemonRes.BaseExperience)
-	if rval > 40 {
-		fmt.Printf("%s escaped!\n", pokemonName)
-		return nil
+	if shown == 0 && len(exploreRes.PokemonEncounters) > 0 {
+		fmt.Printf("No pokemon of %s live here, try another version\n", cfg.version)
 	}
+	printStaleNotice(exploreRes.Stale)
 
-	fmt.Printf("%s was caught!\n", pokemonName)
-	cfg.caughtPokemon[pokemonName] = pokemonRes
 	return nil
 }
 
 // commandInspect: inpsect a pokemon if it is in your pokedex
-func commandInspect(cfg *config, args ...string) error {
+func commandInspect(ctx context.Context, cfg *config, args ...string) error {
 	pokemonName := strings.Join(args[:], "")
 	if pe, exists := cfg.caughtPokemon[pokemonName]; exists {
 		fmt.Printf("Name: %s\n", pe.Name)
+		fmt.Printf("Level: %d\n", pe.Level)
 		fmt.Printf("Height: %d\n", pe.Height)
 		fmt.Printf("Weight: %d\n", pe.Weight)
 		return nil
@@ -548,7 +215,16 @@ func commandInspect(cfg *config, args ...string) error {
 	return nil
 }
 
-func commandPokedex(cfg *config, args ...string) error {
+// commandCache: show memory usage of the API caches
+func commandCache(ctx context.Context, cfg *config, args ...string) error {
+	for _, c := range cfg.client.CacheStats() {
+		fmt.Printf("%s: %d entries, %d bytes, %d evicted, %d expired\n",
+			c.Name, c.Entries, c.Bytes, c.Evictions, c.Expired)
+	}
+	return nil
+}
+
+func commandPokedex(ctx context.Context, cfg *config, args ...string) error {
 	fmt.Printf("Your Pokedex:\n")
 	for pokemonName, _ := range cfg.caughtPokemon {
 		fmt.Printf("\t- %s\n", pokemonName)
@@ -571,12 +247,12 @@ func getCommand() map[string]cliCommand {
 		},
 		"map": {
 			name:        "map",
-			description: "Get next 20 locations",
+			description: "Get the next page of locations: map [first|last|page <n>]",
 			callback:    commandMap,
 		},
 		"mapb": {
 			name:        "mapb",
-			description: "Get previous 20 locations",
+			description: "Get the previous page of locations",
 			callback:    commandMapb,
 		},
 		"explore": {
@@ -584,11 +260,36 @@ func getCommand() map[string]cliCommand {
 			description: "explore a given location",
 			callback:    commandExplore,
 		},
+		"encounter": {
+			name:        "encounter",
+			description: "Look for a wild pokemon in the current area: encounter [walk|surf|old-rod|...]",
+			callback:    commandEncounter,
+		},
 		"catch": {
 			name:        "catch",
-			description: "Catch a pokemon with its name",
+			description: "Catch the wild pokemon you encountered: catch [pokemon] [poke|great|ultra|master]",
 			callback:    commandCatch,
 		},
+		"bag": {
+			name:        "bag",
+			description: "List the balls and money in your bag",
+			callback:    commandBag,
+		},
+		"shop": {
+			name:        "shop",
+			description: "List the balls for sale",
+			callback:    commandShop,
+		},
+		"buy": {
+			name:        "buy",
+			description: "Buy balls: buy <ball> [amount]",
+			callback:    commandBuy,
+		},
+		"sell": {
+			name:        "sell",
+			description: "Sell balls for half their price: sell <ball> [amount]",
+			callback:    commandSell,
+		},
 		"inspect": {
 			name:        "inspect",
 			description: "Inspect a pokemon in your pokedex",
@@ -599,7 +300,61 @@ func getCommand() map[string]cliCommand {
 			description: "lists all pokemons in your pokedex",
 			callback:    commandPokedex,
 		},
+		"save": {
+			name:        "save",
+			description: "Save your pokedex to disk",
+			callback:    commandSave,
+		},
+		"load": {
+			name:        "load",
+			description: "Load your pokedex from disk",
+			callback:    commandLoad,
+		},
+		"profile": {
+			name:        "profile",
+			description: "Manage trainers: profile [new|switch|delete <name>|list]",
+			callback:    commandProfile,
+		},
+		"cache": {
+			name:        "cache",
+			description: "Show memory usage of the API caches",
+			callback:    commandCache,
+		},
+		"prefetch": {
+			name:        "prefetch",
+			description: "Cache all areas and pokemon for a day, prefetch bundle keeps them for -offline: prefetch [bundle]",
+			callback:    commandPrefetch,
+		},
+		"version": {
+			name:        "version",
+			description: "Only show pokemon of a game version: version [<name>|all]",
+			callback:    commandVersion,
+		},
+		"verbose": {
+			name:        "verbose",
+			description: "Show API requests and retries: verbose [on|off]",
+			callback:    commandVerbose,
+		},
+	}
+}
+
+// commandVerbose: turn printing of API requests on or off
+func commandVerbose(ctx context.Context, cfg *config, args ...string) error {
+	if len(args) == 0 {
+		cfg.verbose.Store(!cfg.verbose.Load())
+	} else if args[0] == "on" {
+		cfg.verbose.Store(true)
+	} else if args[0] == "off" {
+		cfg.verbose.Store(false)
+	} else {
+		return errors.New("usage: verbose [on|off]")
+	}
+	if cfg.verbose.Load() {
+		fmt.Printf("Verbose output on\n")
+	} else {
+		fmt.Printf("Verbose output off\n")
 	}
+	return nil
 }
 
 func cleanInput(inp string) []string {
@@ -608,33 +363,157 @@ func cleanInput(inp string) []string {
 	return words
 }
 
-func StartRepl() {
+// StartRepl: runs the pokedex until the user exits. It returns an error
+// when it cannot start or when a non-interactive prefetch fails.
+func StartRepl(opts Options) error {
 	scanner := bufio.NewScanner(os.Stdin)
 	cfg := config{}
-	cfg.locationCache = pokecache.NewCache(5 * time.Minute)
-	cfg.exploreCache = pokecache.NewCache(5 * time.Minute)
-	go cfg.locationCache.ReadLoop()
-	go cfg.exploreCache.ReadLoop()
-	cfg.caughtPokemon = make(map[string]pokemonT)
+	cfg.profile = newProfile(defaultProfileName)
+	cfg.verbose.Store(opts.Verbose)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dataDir, dataDirErr := defaultDataDir()
+	if dataDirErr != nil {
+		fmt.Printf("Could not find the data directory, saves and the disk cache are disabled: %v\n", dataDirErr)
+	}
+	conf, confErr := readUserConfig(dataDir)
+	if confErr != nil {
+		fmt.Printf("Could not read config, using defaults: %v\n", confErr)
+	}
+	baseURL, urlErr := resolveBaseURL(opts.BaseURL, conf)
+	if urlErr != nil {
+		return urlErr
+	}
+	pageSize, sizeErr := resolvePageSize(opts.PageSize, conf)
+	if sizeErr != nil {
+		return sizeErr
+	}
+	cfg.pageSize = pageSize
+	clientOpts := []apiCalls.ClientOption{
+		apiCalls.WithRetryPolicy(opts.Retry),
+		apiCalls.WithLogf(func(format string, args ...any) {
+			if cfg.verbose.Load() {
+				fmt.Printf(format+"\n", args...)
+			}
+		}),
+		apiCalls.WithRateLimit(opts.RateLimit, opts.RateBurst),
+		apiCalls.WithRateLimitWait(func(d time.Duration) {
+			if cfg.prefetching.Load() {
+				return
+			}
+			fmt.Printf("(rate limited, waiting %s for the API)\n", d.Round(time.Millisecond))
+		}),
+	}
+	if dataDir != "" {
+		clientOpts = append(clientOpts, apiCalls.WithCacheDir(filepath.Join(dataDir, cacheDirName)))
+	}
+	modes := 0
+	for _, mode := range []string{opts.Offline, opts.Record, opts.Replay} {
+		if mode != "" {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errors.New("use only one of offline, record and replay")
+	}
+	if opts.Record != "" {
+		rec, err := apiCalls.NewRecorder(opts.Record)
+		if err != nil {
+			return fmt.Errorf("could not create cassette: %w", err)
+		}
+		defer rec.Close()
+		clientOpts = append(clientOpts, apiCalls.WithRecorder(rec))
+	}
+	if opts.Replay != "" {
+		cassette, err := apiCalls.LoadCassette(opts.Replay)
+		if err != nil {
+			return fmt.Errorf("could not load cassette: %w", err)
+		}
+		clientOpts = append(clientOpts, apiCalls.WithReplay(cassette))
+	}
+	if opts.Offline != "" {
+		bundle, closeBundle, err := apiCalls.OpenBundle(opts.Offline)
+		if err != nil {
+			return fmt.Errorf("could not open offline bundle: %w", err)
+		}
+		defer closeBundle()
+		clientOpts = append(clientOpts, apiCalls.WithOfflineBundle(bundle))
+	}
+	cfg.client = apiCalls.NewClient(baseURL, clientOpts...)
+	cfg.client.Start(ctx)
+	defer cfg.client.Close()
+
+	if opts.Prefetch || opts.PrefetchBundle != "" {
+		prefetchCtx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		return prefetch(prefetchCtx, &cfg, opts.PrefetchBundle)
+	}
+
+	version, versionErr := lookupVersion(ctx, cfg.client, opts.Version)
+	if versionErr != nil {
+		return versionErr
+	}
+	cfg.version = version
+
+	if dataDir != "" {
+		cfg.dataDir = dataDir
+		cfg.autosave = true
+		if err := openActiveProfile(&cfg); err != nil {
+			// do not overwrite a save we could not read
+			fmt.Printf("Could not load trainer %s, autosave disabled: %v\n", cfg.name, err)
+			cfg.autosave = false
+		}
+	}
+
+	interrupts := newInterrupter()
+	defer interrupts.stop()
+
 	for {
-		fmt.Printf("Pokedex> ") // shell prompt
-		scanner.Scan()
+		prompt := fmt.Sprintf("Pokedex (%s)> ", cfg.name)
+		interrupts.setPrompt(prompt)
+		fmt.Print(prompt) // shell prompt
+		if !scanner.Scan() {
+			// end of input behaves like the exit command
+			fmt.Println()
+			break
+		}
 		if scanner.Text() == "" {
 			continue
 		}
 		words := cleanInput(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
 		commandName := words[0]
 		args := words[1:]
 
 		if command, exists := getCommand()[commandName]; !exists {
-			fmt.Printf("Unknown command %v", commandName)
+			fmt.Printf("Unknown command %v\n", commandName)
 			continue
 		} else {
-			err := command.callback(&cfg, args...)
+			cmdCtx, done := interrupts.commandContext(ctx)
+			err := command.callback(cmdCtx, &cfg, args...)
+			done()
+			if errors.Is(err, errExit) {
+				break
+			}
+			if errors.Is(err, context.Canceled) {
+				fmt.Printf("\n%s interrupted\n", commandName)
+				continue
+			}
 			if err != nil {
 				fmt.Println(err)
 			}
 		}
 
 	}
+
+	if cfg.autosave {
+		if err := saveProfile(cfg.profile, profilePath(&cfg, cfg.name)); err != nil {
+			fmt.Printf("Could not save pokedex: %v\n", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
+	"github.com/abi01shek/pokedexcli/pkg/fakeapi"
+)
+
+// testRetries is how many attempts the test client makes per request
+const testRetries = 3
+
+// newTestConfig: a config for trainer ash with a client that talks to a
+// fake PokeAPI with the default data, retrying quickly and without a rate
+// limit or disk cache
+func newTestConfig(t *testing.T) (*config, *fakeapi.Server) {
+	t.Helper()
+	srv := fakeapi.NewServer()
+	t.Cleanup(srv.Close)
+	client := apiCalls.NewClient(srv.BaseURL(),
+		apiCalls.WithRetryPolicy(apiCalls.RetryPolicy{MaxAttempts: testRetries, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		apiCalls.WithRateLimit(0, 0),
+	)
+	t.Cleanup(client.Close)
+	cfg := &config{profile: newProfile("ash"), client: client, pageSize: apiCalls.DefaultPageSize}
+	return cfg, srv
+}
+
+// run: runs a command line like the REPL does and returns what it printed
+func run(t *testing.T, cfg *config, line string) (string, error) {
+	t.Helper()
+	words := cleanInput(line)
+	cmd, exists := getCommand()[words[0]]
+	if !exists {
+		t.Fatalf("unknown command %s", words[0])
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		output <- string(out)
+	}()
+	cmdErr := cmd.callback(context.Background(), cfg, words[1:]...)
+	os.Stdout = stdout
+	w.Close()
+	return <-output, cmdErr
+}
+
+// mustRun: like run but fails the test when the command fails
+func mustRun(t *testing.T, cfg *config, line string) string {
+	t.Helper()
+	out, err := run(t, cfg, line)
+	if err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return out
+}
+
+// areaNames: the names of the default areas from first to last, counting
+// from 1
+func areaNames(first int, last int) []string {
+	names := []string{}
+	for n := first; n <= last; n++ {
+		names = append(names, fmt.Sprintf("fake-area-%d", n))
+	}
+	return names
+}
+
+// checkMapPage: checks that out lists exactly the areas first to last and
+// the page footer
+func checkMapPage(t *testing.T, out string, first int, last int, page int) {
+	t.Helper()
+	want := strings.Join(areaNames(first, last), "\n") + fmt.Sprintf("\nPage %d of 3\n", page)
+	if out != want {
+		t.Errorf("map printed\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestMapPagesForward(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	checkMapPage(t, mustRun(t, cfg, "map"), 1, 20, 1)
+	checkMapPage(t, mustRun(t, cfg, "map"), 21, 40, 2)
+	checkMapPage(t, mustRun(t, cfg, "map"), 41, 45, 3)
+	if cfg.mapOffset != 40 {
+		t.Errorf("mapOffset = %d, want 40", cfg.mapOffset)
+	}
+
+	if _, err := run(t, cfg, "map"); err == nil {
+		t.Error("map past the last page succeeded")
+	}
+	if cfg.mapOffset != 40 {
+		t.Errorf("mapOffset = %d after a failed map, want 40", cfg.mapOffset)
+	}
+}
+
+func TestMapbPagesBack(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	if _, err := run(t, cfg, "mapb"); err == nil {
+		t.Error("mapb before the first map succeeded")
+	}
+	mustRun(t, cfg, "map last")
+	checkMapPage(t, mustRun(t, cfg, "mapb"), 21, 40, 2)
+	checkMapPage(t, mustRun(t, cfg, "mapb"), 1, 20, 1)
+	if _, err := run(t, cfg, "mapb"); err == nil {
+		t.Error("mapb on the first page succeeded")
+	}
+	if cfg.mapOffset != 0 {
+		t.Errorf("mapOffset = %d, want 0", cfg.mapOffset)
+	}
+}
+
+func TestMapJumps(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	checkMapPage(t, mustRun(t, cfg, "map last"), 41, 45, 3)
+	checkMapPage(t, mustRun(t, cfg, "map first"), 1, 20, 1)
+	checkMapPage(t, mustRun(t, cfg, "map page 2"), 21, 40, 2)
+	if _, err := run(t, cfg, "map page 4"); !errors.Is(err, errNoSuchPage) {
+		t.Errorf("map page 4 = %v, want errNoSuchPage", err)
+	}
+	if _, err := run(t, cfg, "map page 0"); err == nil {
+		t.Error("map page 0 succeeded")
+	}
+}
+
+func TestMapPageSize(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+	cfg.pageSize = 7
+
+	out := mustRun(t, cfg, "map last")
+	want := strings.Join(areaNames(43, 45), "\n") + "\nPage 7 of 7\n"
+	if out != want {
+		t.Errorf("map last printed\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestExplore(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	out := mustRun(t, cfg, "explore fake-area-6")
+	want := "Exploring fake-area-6 ...\n" +
+		"You found 200₽ exploring a new area!\n" +
+		"Found Pokemon:\n" +
+		"\t- pikachu (diamond, pearl)\n" +
+		"\t- bulbasaur (diamond)\n" +
+		"\t- charmander (pearl)\n"
+	if out != want {
+		t.Errorf("explore printed\n%s\nwant\n%s", out, want)
+	}
+	if cfg.currentArea != "fake-area-6" {
+		t.Errorf("currentArea = %q, want fake-area-6", cfg.currentArea)
+	}
+	if cfg.money != startingMoney+newAreaReward {
+		t.Errorf("money = %d, want %d", cfg.money, startingMoney+newAreaReward)
+	}
+
+	// an area pays only once
+	if out := mustRun(t, cfg, "explore fake-area-6"); strings.Contains(out, "You found") {
+		t.Errorf("second explore paid again:\n%s", out)
+	}
+	if cfg.money != startingMoney+newAreaReward {
+		t.Errorf("money = %d after exploring again, want %d", cfg.money, startingMoney+newAreaReward)
+	}
+	if cfg.stats.Explored != 2 {
+		t.Errorf("stats.Explored = %d, want 2", cfg.stats.Explored)
+	}
+}
+
+func TestExploreVersion(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	mustRun(t, cfg, "version pearl")
+	out := mustRun(t, cfg, "explore fake-area-6")
+	if strings.Contains(out, "bulbasaur") || !strings.Contains(out, "charmander") {
+		t.Errorf("explore in pearl should list charmander but not bulbasaur of diamond:\n%s", out)
+	}
+	if _, err := run(t, cfg, "version bogus"); err == nil {
+		t.Error("version bogus succeeded")
+	}
+	if cfg.version != "pearl" {
+		t.Errorf("version = %q after an unknown version, want pearl", cfg.version)
+	}
+}
+
+func TestVersionNotChecked(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	srv.Fail("version", "platinum", testRetries)
+
+	out := mustRun(t, cfg, "version Platinum")
+	if !strings.HasPrefix(out, "Could not check game version platinum, using it anyway") {
+		t.Errorf("version while the API fails printed %q, want a warning", out)
+	}
+	if cfg.version != "platinum" {
+		t.Errorf("version = %q, want platinum", cfg.version)
+	}
+}
+
+func TestExploreNotFound(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	mustRun(t, cfg, "explore fake-area-1")
+
+	_, err := run(t, cfg, "explore nowhere")
+	if !errors.Is(err, apiCalls.ErrNotFound) {
+		t.Errorf("explore nowhere = %v, want ErrNotFound", err)
+	}
+	if cfg.currentArea != "fake-area-1" {
+		t.Errorf("currentArea = %q after a failed explore, want fake-area-1", cfg.currentArea)
+	}
+	if cfg.money != startingMoney+newAreaReward {
+		t.Errorf("money = %d after a failed explore, want %d", cfg.money, startingMoney+newAreaReward)
+	}
+	// a 404 is final, it is not retried
+	if n := srv.Requests("location-area", "nowhere"); n != 1 {
+		t.Errorf("explore nowhere made %d requests, want 1", n)
+	}
+}
+
+func TestExploreUsage(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+
+	for _, line := range []string{"explore", "explore fake-area-1 fake-area-2"} {
+		if _, err := run(t, cfg, line); err == nil {
+			t.Errorf("%s succeeded", line)
+		}
+	}
+	if cfg.currentArea != "" || cfg.money != startingMoney {
+		t.Errorf("currentArea %q and money %d changed without an area", cfg.currentArea, cfg.money)
+	}
+	if n := srv.Requests("location-area", ""); n != 0 {
+		t.Errorf("explore without an area made %d requests", n)
+	}
+}
+
+// addTestArea: adds an area where only pokemon lives, always at level
+func addTestArea(srv *fakeapi.Server, name string, pokemon string, level int) {
+	srv.Set("location-area", name, fakeapi.LocationArea(1000, name, fakeapi.Encounter{
+		Pokemon:  pokemon,
+		Versions: []string{"diamond"},
+		Method:   "walk",
+		Chance:   100,
+		MinLevel: level,
+		MaxLevel: level,
+	}))
+}
+
+// meet: explores area and encounters its pokemon
+func meet(t *testing.T, cfg *config, area string) {
+	t.Helper()
+	mustRun(t, cfg, "explore "+area)
+	mustRun(t, cfg, "encounter")
+}
+
+func TestCatchInspectPokedex(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	addTestArea(srv, "pikachu-field", "pikachu", 7)
+	cfg.bag["master-ball"] = 1
+
+	if out := mustRun(t, cfg, "inspect pikachu"); out != "Pokemon pikachu does not exist in your pokedex\n" {
+		t.Errorf("inspect before the catch printed %q", out)
+	}
+	if _, err := run(t, cfg, "catch"); err == nil {
+		t.Error("catch without an encounter succeeded")
+	}
+
+	meet(t, cfg, "pikachu-field")
+	if cfg.encounter == nil || cfg.encounter.name != "pikachu" || cfg.encounter.level != 7 {
+		t.Fatalf("encounter = %+v, want pikachu at level 7", cfg.encounter)
+	}
+	if _, err := run(t, cfg, "catch bulbasaur master"); err == nil {
+		t.Error("catching a pokemon that was not encountered succeeded")
+	}
+
+	out := mustRun(t, cfg, "catch pikachu master")
+	if out != "Throwing a Master Ball at pikachu...\npikachu was caught!\n" {
+		t.Errorf("catch printed %q", out)
+	}
+	if cfg.bag["master-ball"] != 0 {
+		t.Errorf("master balls = %d after the throw, want 0", cfg.bag["master-ball"])
+	}
+	if cfg.encounter != nil {
+		t.Error("the encounter is still there after the catch")
+	}
+	if cfg.stats.Caught != 1 {
+		t.Errorf("stats.Caught = %d, want 1", cfg.stats.Caught)
+	}
+
+	out = mustRun(t, cfg, "inspect pikachu")
+	if out != "Name: pikachu\nLevel: 7\nHeight: 4\nWeight: 60\n" {
+		t.Errorf("inspect printed %q", out)
+	}
+	if out := mustRun(t, cfg, "pokedex"); out != "Your Pokedex:\n\t- pikachu\n" {
+		t.Errorf("pokedex printed %q", out)
+	}
+}
+
+func TestCatchStatusModifier(t *testing.T) {
+	pokeBall, _ := findBall(defaultBall)
+	attempt := catchAttempt{captureRate: 45, maxHP: 20, hp: 20, ball: pokeBall}
+	base, _ := attempt.shakeThreshold()
+
+	for status, bonus := range map[statusCondition]float64{
+		statusNone:      1,
+		statusSleep:     2,
+		statusFreeze:    2,
+		statusParalysis: 1.5,
+		statusPoison:    1.5,
+		statusBurn:      1.5,
+	} {
+		attempt.status = status
+		if a, _ := attempt.shakeThreshold(); a != base*bonus {
+			t.Errorf("modified catch rate with %s = %g, want %g", status, a, base*bonus)
+		}
+	}
+}
+
+func TestCatchWithoutBalls(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	addTestArea(srv, "pikachu-field", "pikachu", 7)
+	meet(t, cfg, "pikachu-field")
+
+	if _, err := run(t, cfg, "catch master"); err == nil {
+		t.Error("catch with an empty master ball pocket succeeded")
+	}
+	if n := srv.Requests("pokemon", "pikachu"); n != 0 {
+		t.Errorf("catch without a ball made %d requests", n)
+	}
+}
+
+func TestCatchNotFound(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	addTestArea(srv, "glitch-city", "missingno", 5)
+	meet(t, cfg, "glitch-city")
+	balls := cfg.bag[defaultBall]
+
+	if _, err := run(t, cfg, "catch"); !errors.Is(err, apiCalls.ErrNotFound) {
+		t.Errorf("catch missingno = %v, want ErrNotFound", err)
+	}
+	if cfg.bag[defaultBall] != balls {
+		t.Errorf("poke balls = %d after a failed catch, want %d", cfg.bag[defaultBall], balls)
+	}
+	if len(cfg.caughtPokemon) != 0 || cfg.stats.Caught+cfg.stats.Escaped != 0 {
+		t.Errorf("a failed catch changed the pokedex %v or stats %+v", cfg.caughtPokemon, cfg.stats)
+	}
+}
+
+func TestCatchRetriesServerErrors(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	addTestArea(srv, "pikachu-field", "pikachu", 7)
+	cfg.bag["master-ball"] = 1
+	meet(t, cfg, "pikachu-field")
+
+	srv.Fail("pokemon", "pikachu", testRetries-1)
+	mustRun(t, cfg, "catch master")
+	if _, caught := cfg.caughtPokemon["pikachu"]; !caught {
+		t.Error("pikachu was not caught after the API recovered")
+	}
+	if n := srv.Requests("pokemon", "pikachu"); n != testRetries {
+		t.Errorf("catch made %d requests for pikachu, want %d", n, testRetries)
+	}
+}
+
+func TestCatchGivesUpAfterRetries(t *testing.T) {
+	cfg, srv := newTestConfig(t)
+	addTestArea(srv, "pikachu-field", "pikachu", 7)
+	cfg.bag["master-ball"] = 1
+	meet(t, cfg, "pikachu-field")
+
+	srv.Fail("pokemon", "pikachu", testRetries)
+	_, err := run(t, cfg, "catch master")
+	if err == nil || errors.Is(err, apiCalls.ErrNotFound) {
+		t.Errorf("catch while the API fails = %v, want a server error", err)
+	}
+	if n := srv.Requests("pokemon", "pikachu"); n != testRetries {
+		t.Errorf("catch made %d requests for pikachu, want %d", n, testRetries)
+	}
+	if cfg.bag["master-ball"] != 1 || cfg.encounter == nil {
+		t.Error("a failed catch used up the ball or the encounter")
+	}
+}
+
+func TestPokedexEmpty(t *testing.T) {
+	cfg, _ := newTestConfig(t)
+
+	if out := mustRun(t, cfg, "pokedex"); out != "Your Pokedex:\n" {
+		t.Errorf("pokedex printed %q", out)
+	}
+}
+
+func TestLoadVersion6Save(t *testing.T) {
+	v6 := `{"version":6,"trainer":"ash","map_offset":20,"current_area":"fake-area-6",` +
+		`"area_pokemon":["pikachu"],"caught_pokemon":{},"bag":{"poke-ball":3},"money":3200,` +
+		`"explored_areas":["fake-area-6"],"stats":{"explored":1}}`
+	data, err := migrateSave([]byte(v6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "area_pokemon") {
+		t.Errorf("migrated save still has area_pokemon: %s", data)
+	}
+
+	path := filepath.Join(t.TempDir(), "ash.json")
+	if err := os.WriteFile(path, []byte(v6), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadProfile("ash", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.currentArea != "fake-area-6" || p.mapOffset != 20 || p.money != 3200 || p.bag[defaultBall] != 3 {
+		t.Errorf("loaded profile %+v does not match the save", p)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interrupter turns Ctrl-C into cancelling the running command instead of
+// killing the whole pokedex. At the prompt Ctrl-C just starts a new line.
+type interrupter struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	prompt string
+	sigs   chan os.Signal
+	done   chan struct{}
+}
+
+func newInterrupter() *interrupter {
+	in := new(interrupter)
+	in.sigs = make(chan os.Signal, 1)
+	in.done = make(chan struct{})
+	signal.Notify(in.sigs, os.Interrupt)
+	go in.loop()
+	return in
+}
+
+func (in *interrupter) loop() {
+	for {
+		select {
+		case <-in.done:
+			return
+		case <-in.sigs:
+		}
+		in.mu.Lock()
+		if in.cancel != nil {
+			in.cancel()
+		} else {
+			fmt.Printf("\n%s", in.prompt)
+		}
+		in.mu.Unlock()
+	}
+}
+
+// setPrompt: the prompt to show again when Ctrl-C is pressed while idle
+func (in *interrupter) setPrompt(prompt string) {
+	in.mu.Lock()
+	in.prompt = prompt
+	in.mu.Unlock()
+}
+
+// commandContext: returns a context for one command that Ctrl-C cancels.
+// The returned function must be called once the command is done.
+func (in *interrupter) commandContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	in.mu.Lock()
+	in.cancel = cancel
+	in.mu.Unlock()
+	return ctx, func() {
+		in.mu.Lock()
+		in.cancel = nil
+		in.mu.Unlock()
+		cancel()
+	}
+}
+
+// stop: restores the default Ctrl-C behaviour
+func (in *interrupter) stop() {
+	signal.Stop(in.sigs)
+	close(in.done)
+}
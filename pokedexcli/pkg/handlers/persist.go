@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/abi01shek/pokedexcli/pkg/atomicfile"
+)
+
+const dataDirName = ".pokedexcli"
+const legacySaveFileName = "pokedex.json"
+
+// saveVersion is the current version of the save file format. Bump it
+// whenever saveFileT changes and register a migration from the previous
+// version in saveMigrations.
+const saveVersion = 7
+
+var errNoSavePath = errors.New("no save location available")
+
+// saveFileT is the on disk representation of a trainer profile
+type saveFileT struct {
+	Version       int                       `json:"version"`
+	Trainer       string                    `json:"trainer"`
+	MapOffset     int                       `json:"map_offset"`
+	CurrentArea   string                    `json:"current_area"`
+	CaughtPokemon map[string]caughtPokemonT `json:"caught_pokemon"`
+	Bag           map[string]int            `json:"bag"`
+	Money         int                       `json:"money"`
+	ExploredAreas []string                  `json:"explored_areas"`
+	Stats         trainerStats              `json:"stats"`
+}
+
+// saveMigrations maps a save file version to the function that upgrades
+// a raw save of that version to the next version
+var saveMigrations = map[int]func(raw map[string]json.RawMessage) error{
+	// version 2 adds the trainer name, current area and stats
+	1: func(raw map[string]json.RawMessage) error {
+		caught := map[string]json.RawMessage{}
+		if rawCaught, exists := raw["caught_pokemon"]; exists {
+			if err := json.Unmarshal(rawCaught, &caught); err != nil {
+				return err
+			}
+		}
+		stats, err := json.Marshal(trainerStats{Caught: len(caught)})
+		if err != nil {
+			return err
+		}
+		raw["stats"] = stats
+		return nil
+	},
+	// version 3 keeps the offset of the current map page instead of the
+	// links to the next and previous pages
+	2: func(raw map[string]json.RawMessage) error {
+		next, prev := "", ""
+		if rawNext, exists := raw["location_next"]; exists {
+			if err := json.Unmarshal(rawNext, &next); err != nil {
+				return err
+			}
+		}
+		if rawPrev, exists := raw["location_prev"]; exists {
+			if err := json.Unmarshal(rawPrev, &prev); err != nil {
+				return err
+			}
+		}
+		delete(raw, "location_next")
+		delete(raw, "location_prev")
+
+		mapOffset := -1
+		if offset, limit, ok := pageLinkOffset(next); ok {
+			mapOffset = max(offset-limit, 0)
+		} else if offset, limit, ok := pageLinkOffset(prev); ok {
+			mapOffset = offset + limit
+		}
+		raw["map_offset"], _ = json.Marshal(mapOffset)
+		return nil
+	},
+	// version 4 adds the bag, existing trainers get the starter balls
+	3: func(raw map[string]json.RawMessage) error {
+		bag, err := json.Marshal(starterBag())
+		if err != nil {
+			return err
+		}
+		raw["bag"] = bag
+		return nil
+	},
+	// version 5 adds money and the areas that already paid out, existing
+	// trainers get the starting money and no reward for their current area
+	4: func(raw map[string]json.RawMessage) error {
+		currentArea := ""
+		if rawArea, exists := raw["current_area"]; exists {
+			if err := json.Unmarshal(rawArea, &currentArea); err != nil {
+				return err
+			}
+		}
+		exploredAreas := []string{}
+		if currentArea != "" {
+			exploredAreas = append(exploredAreas, currentArea)
+		}
+		raw["explored_areas"], _ = json.Marshal(exploredAreas)
+		raw["money"], _ = json.Marshal(startingMoney)
+		return nil
+	},
+	// version 6 adds the level pokemon were caught at
+	5: func(raw map[string]json.RawMessage) error {
+		rawCaught, exists := raw["caught_pokemon"]
+		if !exists {
+			return nil
+		}
+		caught := map[string]map[string]json.RawMessage{}
+		if err := json.Unmarshal(rawCaught, &caught); err != nil {
+			return err
+		}
+		for _, pokemon := range caught {
+			pokemon["level"], _ = json.Marshal(defaultWildLevel)
+		}
+		var err error
+		raw["caught_pokemon"], err = json.Marshal(caught)
+		return err
+	},
+	// version 7 drops the pokemon of the current area, explore lists them
+	// and encounter reads them from PokeAPI again
+	6: func(raw map[string]json.RawMessage) error {
+		delete(raw, "area_pokemon")
+		return nil
+	},
+}
+
+// pageLinkOffset: the offset and limit of a location-area page link
+func pageLinkOffset(link string) (offset int, limit int, ok bool) {
+	u, err := url.Parse(link)
+	if link == "" || err != nil {
+		return 0, 0, false
+	}
+	offset, offsetErr := strconv.Atoi(u.Query().Get("offset"))
+	limit, limitErr := strconv.Atoi(u.Query().Get("limit"))
+	if offsetErr != nil || limitErr != nil {
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// defaultDataDir: returns the directory in the users home where the pokedex keeps its data
+func defaultDataDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, dataDirName), nil
+}
+
+// migrateSave: upgrades a raw save file to the current saveVersion
+func migrateSave(data []byte) ([]byte, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid save file: %w", err)
+	}
+
+	version := 0
+	rawVersion, exists := raw["version"]
+	if !exists {
+		return nil, errors.New("invalid save file: missing version")
+	}
+	if err := json.Unmarshal(rawVersion, &version); err != nil {
+		return nil, fmt.Errorf("invalid save file version: %w", err)
+	}
+	if version > saveVersion {
+		return nil, fmt.Errorf("save file version %d is newer than supported version %d", version, saveVersion)
+	}
+
+	for ; version < saveVersion; version++ {
+		migrate, exists := saveMigrations[version]
+		if !exists {
+			return nil, fmt.Errorf("no migration from save file version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("migrating save file from version %d: %w", version, err)
+		}
+	}
+
+	raw["version"], _ = json.Marshal(saveVersion)
+	return json.Marshal(raw)
+}
+
+// saveProfile: writes a trainer profile to path
+func saveProfile(p *profile, path string) error {
+	save := saveFileT{
+		Version:       saveVersion,
+		Trainer:       p.name,
+		MapOffset:     p.mapOffset,
+		CurrentArea:   p.currentArea,
+		CaughtPokemon: p.caughtPokemon,
+		Bag:           p.bag,
+		Money:         p.money,
+		Stats:         p.stats,
+	}
+	for area := range p.exploredAreas {
+		save.ExploredAreas = append(save.ExploredAreas, area)
+	}
+	data, err := json.Marshal(save)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data)
+}
+
+// loadProfile: reads the profile of trainer name from path
+func loadProfile(name string, path string) (*profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = migrateSave(data)
+	if err != nil {
+		return nil, err
+	}
+
+	save := saveFileT{}
+	if err := json.Unmarshal(data, &save); err != nil {
+		return nil, fmt.Errorf("invalid save file: %w", err)
+	}
+
+	p := newProfile(name)
+	p.mapOffset = save.MapOffset
+	p.currentArea = save.CurrentArea
+	if save.CaughtPokemon != nil {
+		p.caughtPokemon = save.CaughtPokemon
+	}
+	if save.Bag != nil {
+		p.bag = save.Bag
+	}
+	p.money = save.Money
+	for _, area := range save.ExploredAreas {
+		p.exploredAreas[area] = true
+	}
+	p.stats = save.Stats
+	return p, nil
+}
+
+// commandSave: save the active profile to disk
+func commandSave(ctx context.Context, cfg *config, args ...string) error {
+	if cfg.dataDir == "" {
+		return errNoSavePath
+	}
+	path := profilePath(cfg, cfg.name)
+	if err := saveProfile(cfg.profile, path); err != nil {
+		return err
+	}
+	fmt.Printf("Pokedex saved to %s\n", path)
+	return nil
+}
+
+// commandLoad: reload the active profile from disk, discarding unsaved progress
+func commandLoad(ctx context.Context, cfg *config, args ...string) error {
+	if cfg.dataDir == "" {
+		return errNoSavePath
+	}
+	path := profilePath(cfg, cfg.name)
+	p, err := loadProfile(cfg.name, path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no saved pokedex found at %s", path)
+		}
+		return err
+	}
+	cfg.profile = p
+	cfg.autosave = true
+	fmt.Printf("Loaded %d pokemon from %s\n", len(cfg.caughtPokemon), path)
+	return nil
+}
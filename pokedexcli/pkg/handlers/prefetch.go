@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
+)
+
+const bundleDirName = "bundle"
+
+// commandPrefetch: download every area, pokemon and ball into the response
+// cache, prefetch bundle also keeps them in the bundle directory for offline
+// play
+func commandPrefetch(ctx context.Context, cfg *config, args ...string) error {
+	bundleDir := ""
+	if len(args) == 1 && args[0] == "bundle" {
+		if cfg.dataDir == "" {
+			return errNoSavePath
+		}
+		bundleDir = filepath.Join(cfg.dataDir, bundleDirName)
+	} else if len(args) != 0 {
+		return errors.New("usage: prefetch [bundle]")
+	}
+	return prefetch(ctx, cfg, bundleDir)
+}
+
+// prefetch: runs a prefetch with a status line that shows its progress
+func prefetch(ctx context.Context, cfg *config, bundleDir string) error {
+	cfg.prefetching.Store(true)
+	defer cfg.prefetching.Store(false)
+
+	statusLen := 0
+	printStatus := func(status string) {
+		fmt.Printf("\r%-*s", statusLen, status)
+		statusLen = len(status)
+	}
+	shopItems := []string{}
+	for _, ball := range balls {
+		shopItems = append(shopItems, ball.name)
+	}
+	result, err := cfg.client.Prefetch(ctx, bundleDir, cfg.pageSize, func(p apiCalls.PrefetchProgress) {
+		if p.Err != nil {
+			printStatus("")
+			fmt.Printf("\rskipped %s: %v\n", p.Name, p.Err)
+			statusLen = 0
+			return
+		}
+		if p.Total == 0 {
+			printStatus(fmt.Sprintf("Prefetching %s: %d", p.Stage, p.Done))
+		} else {
+			printStatus(fmt.Sprintf("Prefetching %s: %d/%d %s", p.Stage, p.Done, p.Total, p.Name))
+		}
+	}, shopItems...)
+	printStatus("")
+	fmt.Printf("\r")
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("Prefetch stopped, run it again to resume\n")
+		}
+		return err
+	}
+
+	fmt.Printf("Prefetched %d areas, %d versions, %d pokemon, %d species and %d items, %d were already there, %d failed\n",
+		result.Areas, result.Versions, result.Pokemon, result.Species, result.Items, result.Had, result.Failed)
+	if bundleDir != "" {
+		fmt.Printf("Bundle written to %s, play it with -offline %s\n", bundleDir, bundleDir)
+	}
+	return nil
+}
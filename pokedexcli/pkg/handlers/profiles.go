@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abi01shek/pokedexcli/pkg/atomicfile"
+)
+
+const profilesDirName = "profiles"
+const activeProfileFileName = "active_profile"
+const defaultProfileName = "default"
+
+var validProfileName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// profile: the state of a single trainer, saved in the profiles directory
+type profile struct {
+	name          string
+	mapOffset     int // offset of the map page last shown, -1 before the first map
+	currentArea   string
+	caughtPokemon map[string]caughtPokemonT
+	bag           map[string]int // ball name to count
+	money         int
+	exploredAreas map[string]bool // areas that already paid newAreaReward
+	encounter     *wildEncounter  // the wild pokemon being faced, nil when there is none
+	stats         trainerStats
+}
+
+type trainerStats struct {
+	Explored int `json:"explored"`
+	Caught   int `json:"caught"`
+	Escaped  int `json:"escaped"`
+}
+
+// newProfile: create an empty profile for a new trainer
+func newProfile(name string) *profile {
+	p := new(profile)
+	p.name = name
+	p.mapOffset = -1
+	p.caughtPokemon = make(map[string]caughtPokemonT)
+	p.bag = starterBag()
+	p.money = startingMoney
+	p.exploredAreas = make(map[string]bool)
+	return p
+}
+
+func profilePath(cfg *config, name string) string {
+	return filepath.Join(cfg.dataDir, profilesDirName, name+".json")
+}
+
+// listProfiles: names of all saved profiles in alphabetical order
+func listProfiles(cfg *config) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(cfg.dataDir, profilesDirName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if name, found := strings.CutSuffix(entry.Name(), ".json"); found && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func profileExists(cfg *config, name string) bool {
+	_, err := os.Stat(profilePath(cfg, name))
+	return err == nil
+}
+
+func setActiveProfile(cfg *config, name string) error {
+	return atomicfile.WriteFile(filepath.Join(cfg.dataDir, activeProfileFileName), []byte(name+"\n"))
+}
+
+// openActiveProfile: loads the profile that was active when the pokedex was
+// last used. A save from before profiles existed becomes the default profile.
+func openActiveProfile(cfg *config) error {
+	name := defaultProfileName
+	if data, err := os.ReadFile(filepath.Join(cfg.dataDir, activeProfileFileName)); err == nil {
+		if active := strings.TrimSpace(string(data)); validProfileName.MatchString(active) {
+			name = active
+		}
+	}
+
+	legacyPath := filepath.Join(cfg.dataDir, legacySaveFileName)
+	if name == defaultProfileName && !profileExists(cfg, name) {
+		if _, err := os.Stat(legacyPath); err == nil {
+			if err := os.MkdirAll(filepath.Join(cfg.dataDir, profilesDirName), 0o755); err != nil {
+				return err
+			}
+			if err := os.Rename(legacyPath, profilePath(cfg, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	cfg.profile = newProfile(name)
+	p, err := loadProfile(name, profilePath(cfg, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	cfg.profile = p
+	return nil
+}
+
+// switchProfile: saves the active profile and makes name the active one
+func switchProfile(cfg *config, p *profile) error {
+	if cfg.autosave {
+		if err := saveProfile(cfg.profile, profilePath(cfg, cfg.name)); err != nil {
+			return fmt.Errorf("could not save profile %s: %w", cfg.name, err)
+		}
+	}
+	if err := saveProfile(p, profilePath(cfg, p.name)); err != nil {
+		return err
+	}
+	if err := setActiveProfile(cfg, p.name); err != nil {
+		return err
+	}
+	cfg.profile = p
+	cfg.autosave = true
+	return nil
+}
+
+// commandProfile: manage trainer profiles
+func commandProfile(ctx context.Context, cfg *config, args ...string) error {
+	if cfg.dataDir == "" {
+		return errNoSavePath
+	}
+	if len(args) == 0 {
+		fmt.Printf("Trainer: %s\n", cfg.name)
+		fmt.Printf("Pokemon in pokedex: %d\n", len(cfg.caughtPokemon))
+		fmt.Printf("Money: %s\n", formatMoney(cfg.money))
+		fmt.Printf("Areas explored: %d\n", cfg.stats.Explored)
+		fmt.Printf("Pokemon caught: %d\n", cfg.stats.Caught)
+		fmt.Printf("Pokemon escaped: %d\n", cfg.stats.Escaped)
+		return nil
+	}
+
+	if args[0] == "list" {
+		names, err := listProfiles(cfg)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 || !profileExists(cfg, cfg.name) {
+			names = append(names, cfg.name)
+			sort.Strings(names)
+		}
+		fmt.Printf("Trainers:\n")
+		for _, name := range names {
+			marker := " "
+			if name == cfg.name {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, name)
+		}
+		return nil
+	}
+
+	if len(args) != 2 {
+		return errors.New("usage: profile [new|switch|delete <name>|list]")
+	}
+	name := args[1]
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid trainer name %s: use letters, digits, - and _", name)
+	}
+
+	switch args[0] {
+	case "new":
+		if name == cfg.name || profileExists(cfg, name) {
+			return fmt.Errorf("trainer %s already exists", name)
+		}
+		if err := switchProfile(cfg, newProfile(name)); err != nil {
+			return err
+		}
+		fmt.Printf("Welcome, trainer %s!\n", name)
+	case "switch":
+		if name == cfg.name {
+			return fmt.Errorf("trainer %s is already active", name)
+		}
+		p, err := loadProfile(name, profilePath(cfg, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("trainer %s does not exist", name)
+			}
+			return err
+		}
+		if err := switchProfile(cfg, p); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to trainer %s\n", name)
+	case "delete":
+		if name == cfg.name {
+			return errors.New("cannot delete the active trainer, switch to another one first")
+		}
+		if err := os.Remove(profilePath(cfg, name)); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("trainer %s does not exist", name)
+			}
+			return err
+		}
+		fmt.Printf("Deleted trainer %s\n", name)
+	default:
+		return fmt.Errorf("unknown profile command %s", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
+)
+
+const configFileName = "config.json"
+
+// BaseURLEnv is the environment variable that overrides the PokeAPI address
+// from the config file
+const BaseURLEnv = "POKEDEXCLI_BASE_URL"
+
+// userConfigT is the config file in the data directory. Every field is
+// optional.
+type userConfigT struct {
+	BaseURL  string `json:"base_url"`
+	PageSize int    `json:"page_size"`
+}
+
+// readUserConfig: reads the config file in dataDir, a missing file is an
+// empty config
+func readUserConfig(dataDir string) (userConfigT, error) {
+	conf := userConfigT{}
+	if dataDir == "" {
+		return conf, nil
+	}
+	path := filepath.Join(dataDir, configFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return conf, nil
+		}
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// resolveBaseURL: picks the PokeAPI address from the command line, then the
+// environment, then the config file and falls back to the public PokeAPI
+func resolveBaseURL(flagValue string, conf userConfigT) (string, error) {
+	baseURL := apiCalls.DefaultBaseURL
+	if flagValue != "" {
+		baseURL = flagValue
+	} else if env := os.Getenv(BaseURLEnv); env != "" {
+		baseURL = env
+	} else if conf.BaseURL != "" {
+		baseURL = conf.BaseURL
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid PokeAPI address %s: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid PokeAPI address %s: must be an http or https URL", baseURL)
+	}
+	return baseURL, nil
+}
+
+// resolvePageSize: picks the number of locations per map page from the
+// command line, then the config file and falls back to the PokeAPI default
+func resolvePageSize(flagValue int, conf userConfigT) (int, error) {
+	pageSize := apiCalls.DefaultPageSize
+	if flagValue != 0 {
+		pageSize = flagValue
+	} else if conf.PageSize != 0 {
+		pageSize = conf.PageSize
+	}
+	if pageSize < 1 {
+		return 0, fmt.Errorf("invalid page size %d: must be at least 1", pageSize)
+	}
+	return pageSize, nil
+}
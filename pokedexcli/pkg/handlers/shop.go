@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// startingMoney is what a new trainer has to spend
+const startingMoney = 3000
+
+// newAreaReward is earned the first time a trainer explores an area
+const newAreaReward = 200
+
+// maxTradeAmount is the most balls a single buy or sell trades, like the
+// item limit of the games
+const maxTradeAmount = 999
+
+// formatMoney: an amount of Pokedollars
+func formatMoney(amount int) string {
+	return fmt.Sprintf("%d₽", amount)
+}
+
+// commandShop: list the balls for sale with their price
+func commandShop(ctx context.Context, cfg *config, args ...string) error {
+	fmt.Printf("For sale:\n")
+	for _, ball := range balls {
+		item, err := cfg.client.GetItem(ctx, ball.name)
+		if err != nil {
+			return err
+		}
+		if item.Cost > 0 {
+			fmt.Printf("\t- %s: %s\n", ball.title, formatMoney(item.Cost))
+		}
+	}
+	fmt.Printf("You have %s\n", formatMoney(cfg.money))
+	return nil
+}
+
+// parseTrade: the ball and amount of a buy or sell command
+func parseTrade(command string, args []string) (ballT, int, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return ballT{}, 0, fmt.Errorf("usage: %s <ball> [amount]", command)
+	}
+	ball, found := findBall(args[0])
+	if !found {
+		return ballT{}, 0, fmt.Errorf("the shop does not trade %s", args[0])
+	}
+	amount := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > maxTradeAmount {
+			return ballT{}, 0, fmt.Errorf("invalid amount %s, trade 1 to %d at a time", args[1], maxTradeAmount)
+		}
+		amount = n
+	}
+	return ball, amount, nil
+}
+
+// commandBuy: buy balls from the shop
+func commandBuy(ctx context.Context, cfg *config, args ...string) error {
+	ball, amount, err := parseTrade("buy", args)
+	if err != nil {
+		return err
+	}
+	item, err := cfg.client.GetItem(ctx, ball.name)
+	if err != nil {
+		return err
+	}
+	if item.Cost <= 0 {
+		return fmt.Errorf("%ss are not for sale", ball.title)
+	}
+	// compare before multiplying so a huge amount cannot overflow the price
+	if amount > cfg.money/item.Cost {
+		return fmt.Errorf("%s(s) cost %s each, you can afford %d with %s", ball.title, formatMoney(item.Cost), cfg.money/item.Cost, formatMoney(cfg.money))
+	}
+	price := item.Cost * amount
+
+	cfg.money -= price
+	cfg.bag[ball.name] += amount
+	fmt.Printf("Bought %d %s(s) for %s, you have %s left\n", amount, ball.title, formatMoney(price), formatMoney(cfg.money))
+	return nil
+}
+
+// commandSell: sell balls to the shop for half their price
+func commandSell(ctx context.Context, cfg *config, args ...string) error {
+	ball, amount, err := parseTrade("sell", args)
+	if err != nil {
+		return err
+	}
+	if cfg.bag[ball.name] < amount {
+		return fmt.Errorf("you only have %d %s(s)", cfg.bag[ball.name], ball.title)
+	}
+	item, err := cfg.client.GetItem(ctx, ball.name)
+	if err != nil {
+		return err
+	}
+	if item.Cost <= 0 {
+		return fmt.Errorf("the shop does not buy %ss", ball.title)
+	}
+
+	price := item.Cost / 2 * amount
+	cfg.money += price
+	cfg.bag[ball.name] -= amount
+	fmt.Printf("Sold %d %s(s) for %s, you have %s now\n", amount, ball.title, formatMoney(price), formatMoney(cfg.money))
+	return nil
+}
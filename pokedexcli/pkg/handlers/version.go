@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abi01shek/pokedexcli/pkg/apiCalls"
+)
+
+// allVersions turns the version filter off
+const allVersions = "all"
+
+// pokemonVersions: the game versions each pokemon of an area is found in, in
+// the order PokeAPI lists them
+func pokemonVersions(area exploreAreaT) map[string][]string {
+	versions := make(map[string][]string)
+	for _, pe := range area.PokemonEncounters {
+		for _, vd := range pe.VersionDetails {
+			versions[pe.Pokemon.Name] = append(versions[pe.Pokemon.Name], vd.Version.Name)
+		}
+	}
+	return versions
+}
+
+// lookupVersion: the version to filter by, checked against PokeAPI. all and
+// the empty name select every version. Only a 404 from PokeAPI rejects the
+// name, when it cannot be checked, offline or on network errors, it is kept
+// with a warning.
+func lookupVersion(ctx context.Context, client *apiCalls.Client, name string) (string, error) {
+	name = strings.ToLower(name)
+	if name == "" || name == allVersions {
+		return "", nil
+	}
+	_, err := client.GetVersion(ctx, name)
+	var missing *apiCalls.MissingError
+	if errors.Is(err, apiCalls.ErrNotFound) && !errors.As(err, &missing) {
+		return "", fmt.Errorf("unknown game version %s", name)
+	}
+	if err != nil {
+		fmt.Printf("Could not check game version %s, using it anyway: %v\n", name, err)
+	}
+	return name, nil
+}
+
+// inVersion: whether versions contains version, any version matches when
+// no version is selected
+func inVersion(versions []string, version string) bool {
+	if version == "" {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// commandVersion: show or select the game version explore and encounter
+// use, version all shows the pokemon of every version
+func commandVersion(ctx context.Context, cfg *config, args ...string) error {
+	if len(args) > 1 {
+		return errors.New("usage: version [<name>|all]")
+	}
+	if len(args) == 1 {
+		version, err := lookupVersion(ctx, cfg.client, args[0])
+		if err != nil {
+			return err
+		}
+		if version != cfg.version {
+			cfg.version = version
+			// the wild pokemon belongs to the game that was left
+			cfg.encounter = nil
+		}
+	}
+	if cfg.version == "" {
+		fmt.Printf("Showing pokemon of all game versions\n")
+	} else {
+		fmt.Printf("Showing pokemon of %s\n", cfg.version)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package pokecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abi01shek/pokedexcli/pkg/atomicfile"
+)
+
+// diskEntry is the file format of a single entry on disk
+type diskEntry struct {
+	Key          string    `json:"key"`
+	ExpTime      time.Time `json:"exp_time"`
+	StaleUntil   time.Time `json:"stale_until,omitempty"`
+	Val          []byte    `json:"val"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+}
+
+// removeAfter: when the entry is no longer useful, not even for revalidation
+func (de diskEntry) removeAfter() time.Time {
+	if de.StaleUntil.After(de.ExpTime) {
+		return de.StaleUntil
+	}
+	return de.ExpTime
+}
+
+// diskStore keeps cache entries as one file per key in dir. Writes go to a
+// temporary file that is renamed into place, so readers never see partial
+// entries. The modification time of a file is when its entry can be
+// removed. Disk errors are not fatal, the cache just behaves as a miss.
+// mu serializes file operations so removing an expired entry can never
+// delete a newer file written for the same key.
+type diskStore struct {
+	mu         sync.Mutex
+	dir        string
+	expiration time.Duration
+	retention  func(e Entry) time.Duration
+}
+
+// path: file name for a key, keys are URLs so they are hashed
+func (d *diskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (d *diskStore) add(key string, e Entry) {
+	de := diskEntry{Key: key, ExpTime: time.Now().Add(d.expiration), Val: e.Val, ETag: e.ETag, LastModified: e.LastModified}
+	de.StaleUntil = de.ExpTime.Add(d.retention(e))
+	data, err := json.Marshal(de)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path := d.path(key)
+	if err := atomicfile.WriteFile(path, data); err != nil {
+		return
+	}
+	// removeIfExpired checks the mtime again under mu, so it never acts on
+	// the write time the file briefly has
+	if err := os.Chtimes(path, time.Time{}, de.removeAfter()); err != nil {
+		os.Remove(path)
+	}
+}
+
+// get: reads the entry for key and the time it expires. Expired entries
+// are returned until they are past their retention.
+func (d *diskStore) get(key string) (e Entry, expTime time.Time, found bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path := d.path(key)
+	de, ok := readDiskEntry(path)
+	if !ok {
+		os.Remove(path)
+		return Entry{}, time.Time{}, false
+	}
+	if de.Key != key {
+		return Entry{}, time.Time{}, false
+	}
+	if time.Now().After(de.removeAfter()) {
+		os.Remove(path)
+		return Entry{}, time.Time{}, false
+	}
+	e = Entry{Val: de.Val, ETag: de.ETag, LastModified: de.LastModified}
+	return e, de.ExpTime, true
+}
+
+// removeExpired: deletes every entry past its retention from the directory.
+// The scan only looks at modification times and runs without mu, which is
+// taken for each file that is removed. Unreadable entries are removed by get.
+func (d *diskStore) removeExpired() {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		if info, err := f.Info(); err == nil && now.After(info.ModTime()) {
+			d.removeIfExpired(filepath.Join(d.dir, f.Name()), now)
+		}
+	}
+}
+
+// removeIfExpired: deletes the file at path unless add replaced it with a
+// fresh entry since the directory was read
+func (d *diskStore) removeIfExpired(path string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if info, err := os.Stat(path); err == nil && now.After(info.ModTime()) {
+		os.Remove(path)
+	}
+}
+
+func readDiskEntry(path string) (diskEntry, bool) {
+	de := diskEntry{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return de, false
+	}
+	if err := json.Unmarshal(data, &de); err != nil {
+		return de, false
+	}
+	return de, true
+}
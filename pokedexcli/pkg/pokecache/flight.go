@@ -0,0 +1,58 @@
+package pokecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errLoadPanicked is what callers waiting on a load get when it panicked
+var errLoadPanicked = errors.New("cache load panicked")
+
+// flight is a load in progress that other callers for the same key wait on
+type flight struct {
+	done chan struct{}
+	val  Entry
+	err  error
+}
+
+// flightGroup deduplicates concurrent loads of the same key
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// do: runs load once for all concurrent callers with the same key and
+// hands every caller the same result. A caller whose ctx is done stops
+// waiting, the load itself only stops when the ctx it was started with is.
+func (g *flightGroup) do(ctx context.Context, key string, load func(ctx context.Context) (Entry, error)) (Entry, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	if fl, exists := g.flights[key]; exists {
+		g.mu.Unlock()
+		select {
+		case <-fl.done:
+			return fl.val, fl.err
+		case <-ctx.Done():
+			return Entry{}, ctx.Err()
+		}
+	}
+	fl := new(flight)
+	fl.done = make(chan struct{})
+	g.flights[key] = fl
+	g.mu.Unlock()
+
+	// a panicking load still releases the key and its waiters, they get
+	// errLoadPanicked while the panic goes on up the loading caller
+	fl.err = errLoadPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		close(fl.done)
+	}()
+	fl.val, fl.err = load(ctx)
+	return fl.val, fl.err
+}
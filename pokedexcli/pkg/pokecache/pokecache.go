@@ -1,70 +1,244 @@
 package pokecache
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
-type cacheEntry struct {
-	expTime time.Time
-	val     []byte
+// Cache stores raw response bodies keyed by URL. It is safe for concurrent
+// use. Memory hits never touch the disk tier, but AddEntry writes through to
+// disk and a memory miss reads the disk, both under the lock of the disk tier.
+type Cache struct {
+	mem              *store[string, Entry]
+	janitor          *janitor
+	disk             *diskStore
+	loads            flightGroup
+	refreshes        sync.WaitGroup
+	revalidateWindow time.Duration
+	staleGrace       time.Duration
 }
 
-type Cache struct {
-	cacheMap   map[string]cacheEntry
-	mu         *sync.RWMutex
-	expiration time.Duration
-	ticker     *time.Ticker
+// Entry is a cached body together with the HTTP validators it was served
+// with, so an expired entry can be revalidated instead of downloaded again
+type Entry struct {
+	Val          []byte
+	ETag         string
+	LastModified string
+	Stale        bool // served past its expiry while a refresh runs in the background
+}
+
+// hasValidators: whether the entry can be revalidated
+func (e Entry) hasValidators() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// Stats is a snapshot of the in memory usage of a cache
+type Stats struct {
+	Entries   int
+	Bytes     int
+	Evictions uint64 // entries dropped to stay within the size limits
+	Expired   uint64 // entries dropped because they expired
+}
+
+// Option configures optional behaviour of a Cache
+type Option func(c *Cache)
+
+// WithDiskDir: also keep entries as files in dir so they survive restarts.
+// Disk entries expire after diskExpiration, which may be longer than the
+// in memory expiration.
+func WithDiskDir(dir string, diskExpiration time.Duration) Option {
+	return func(c *Cache) {
+		c.disk = &diskStore{dir: dir, expiration: diskExpiration, retention: c.retention}
+	}
+}
+
+// WithMaxEntries: keep at most n entries in memory, evicting the least
+// recently used ones first. Zero means no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.mem.maxEntries = n
+	}
+}
+
+// WithMaxBytes: keep at most n bytes of keys and values in memory, evicting
+// the least recently used entries first. Zero means no limit.
+func WithMaxBytes(n int) Option {
+	return func(c *Cache) {
+		c.mem.maxBytes = n
+	}
+}
+
+// WithRevalidateWindow: keep expired entries that have validators for d
+// longer so GetOrLoadEntry can hand them to the loader for revalidation
+func WithRevalidateWindow(d time.Duration) Option {
+	return func(c *Cache) {
+		c.revalidateWindow = d
+	}
+}
+
+// WithStaleWhileRevalidate: for up to grace after an entry expired,
+// GetOrLoadEntry returns it right away marked as Stale and refreshes it in
+// the background instead of making the caller wait for the loader
+func WithStaleWhileRevalidate(grace time.Duration) Option {
+	return func(c *Cache) {
+		c.staleGrace = grace
+	}
 }
 
 // NewCache create a new empty cache with default values
-func NewCache(expiration time.Duration) *Cache {
+func NewCache(expiration time.Duration, opts ...Option) *Cache {
 	c := new(Cache)
-	c.cacheMap = make(map[string]cacheEntry)
-	c.mu = new(sync.RWMutex)
-	c.expiration = expiration
-	c.ticker = time.NewTicker(expiration)
+	c.mem = newStore[string, Entry](expiration)
+	c.mem.sizeOf = func(key string, e Entry) int {
+		return len(key) + len(e.Val) + len(e.ETag) + len(e.LastModified)
+	}
+	c.mem.retention = c.retention
+	c.janitor = newJanitor(expiration)
+	for _, opt := range opts {
+		opt(c)
+	}
 	return c
 }
 
+// retention: how long an expired entry is kept around
+func (c *Cache) retention(e Entry) time.Duration {
+	d := c.staleGrace
+	if e.hasValidators() {
+		d = max(d, c.revalidateWindow)
+	}
+	return d
+}
+
 // Add: add a new string and byte array to the cache
 func (c *Cache) Add(key string, val []byte) {
-	thisCe := new(cacheEntry)
-	thisCe.val = val
-	thisCe.expTime = time.Now().Add(c.expiration)
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.cacheMap[key] = *thisCe
+	c.AddEntry(key, Entry{Val: val})
+}
+
+// AddEntry: add a body with its validators to the cache. Adding an entry
+// again, for example after a successful revalidation, renews its expiry.
+func (c *Cache) AddEntry(key string, e Entry) {
+	c.mem.add(key, e)
+	if c.disk != nil {
+		c.disk.add(key, e)
+	}
 }
 
 // Get: get the byte array for a specific string
 func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if ce, exists := c.cacheMap[key]; exists {
-		// if current time is after the expiration time
-		if time.Now().After(ce.expTime) {
-			delete(c.cacheMap, key)
-			return nil, false
+	e, found := c.getEntry(key)
+	return e.Val, found
+}
+
+func (c *Cache) getEntry(key string) (Entry, bool) {
+	if e, found := c.mem.get(key); found {
+		return e, true
+	}
+	if c.disk == nil {
+		return Entry{}, false
+	}
+
+	// fall back to the disk and keep what we find in memory again. If a
+	// concurrent Add stored a newer value while we read the disk keep that.
+	e, expTime, found := c.disk.get(key)
+	if !found || time.Now().After(expTime) {
+		return Entry{}, false
+	}
+	return c.mem.addIfAbsent(key, e), true
+}
+
+// getStale: an expired entry that is still retained and when it expired
+func (c *Cache) getStale(key string) (Entry, time.Time, bool) {
+	if e, expTime, found := c.mem.getStale(key); found {
+		return e, expTime, true
+	}
+	if c.disk == nil {
+		return Entry{}, time.Time{}, false
+	}
+	return c.disk.get(key)
+}
+
+// GetOrLoad: get the byte array for a key, calling loader to fetch it on a
+// miss. Concurrent misses for the same key share a single loader call and
+// all receive its result and error. Successful loads are added to the cache.
+// The loader should give up when the ctx it is passed is done.
+func (c *Cache) GetOrLoad(ctx context.Context, key string, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	e, err := c.GetOrLoadEntry(ctx, key, func(ctx context.Context, stale *Entry) (Entry, error) {
+		val, err := loader(ctx)
+		return Entry{Val: val}, err
+	})
+	return e.Val, err
+}
+
+// GetOrLoadEntry: like GetOrLoad but the loader also gets the expired entry
+// for key, if one is retained, so it can revalidate it. Returning the stale
+// entry unchanged renews its expiry. Within the WithStaleWhileRevalidate
+// grace window the stale entry is returned right away and the loader runs
+// in the background, detached from the cancellation of ctx.
+func (c *Cache) GetOrLoadEntry(ctx context.Context, key string, loader func(ctx context.Context, stale *Entry) (Entry, error)) (Entry, error) {
+	if e, found := c.getEntry(key); found {
+		return e, nil
+	}
+	load := func(ctx context.Context) (Entry, error) {
+		// a load that finished while we were getting here already added it
+		if e, found := c.getEntry(key); found {
+			return e, nil
 		}
-		return ce.val, true
+		var stale *Entry
+		if e, _, found := c.getStale(key); found {
+			stale = &e
+		}
+		e, err := loader(ctx, stale)
+		if err != nil {
+			return Entry{}, err
+		}
+		c.AddEntry(key, e)
+		return e, nil
 	}
-	return nil, false
+
+	if c.staleGrace > 0 {
+		if e, expTime, found := c.getStale(key); found && time.Since(expTime) <= c.staleGrace {
+			c.refreshes.Add(1)
+			go func() {
+				defer c.refreshes.Done()
+				// a failed refresh keeps serving the stale entry until the grace ends
+				c.loads.do(context.WithoutCancel(ctx), key, load)
+			}()
+			e.Stale = true
+			return e, nil
+		}
+	}
+	return c.loads.do(ctx, key, load)
+}
+
+// Stats: returns the current memory usage and eviction counters
+func (c *Cache) Stats() Stats {
+	return c.mem.stats()
+}
+
+// Start: runs ReadLoop in a new goroutine until ctx is cancelled or the
+// cache is closed
+func (c *Cache) Start(ctx context.Context) {
+	c.janitor.start(ctx, c.clean)
+}
+
+// Close: stops the ticker and waits for goroutines started with Start and
+// for background refreshes to return. The cache can still be used
+// afterwards but is no longer cleaned.
+func (c *Cache) Close() {
+	c.janitor.close()
+	c.refreshes.Wait()
 }
 
 // ReadLoop: loops around the cache and clears out expired data
-// forever loop with a blocking ticker channel
-func (c *Cache) ReadLoop() {
-	for {
-		<-c.ticker.C // block for ticker channel
-		// clean up the caches
-		c.mu.Lock()
-		for k := range c.cacheMap {
-			if time.Now().After(c.cacheMap[k].expTime) {
-				delete(c.cacheMap, k)
-			}
-		}
-		c.mu.Unlock()
-		//time.Sleep(c.expiration)
+// until ctx is cancelled or the cache is closed
+func (c *Cache) ReadLoop(ctx context.Context) {
+	c.janitor.loop(ctx, c.clean)
+}
+
+func (c *Cache) clean() {
+	c.mem.removeExpired()
+	if c.disk != nil {
+		c.disk.removeExpired()
 	}
 }
@@ -0,0 +1,255 @@
+package pokecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	stressExpiration = 5 * time.Millisecond
+	stressDuration   = 200 * time.Millisecond
+	stressWorkers    = 32
+	stressKeys       = 64
+	stressMaxEntries = 16
+	stressMaxBytes   = 1024
+)
+
+// TestCacheConcurrentStress runs Add, AddEntry, Get and GetOrLoadEntry from many goroutines
+// while the janitor expires entries and the LRU limits evict them, so
+// go test -race catches unsynchronized access. It runs with and without the
+// disk tier.
+func TestCacheConcurrentStress(t *testing.T) {
+	for _, disk := range []bool{false, true} {
+		t.Run(fmt.Sprintf("disk=%v", disk), func(t *testing.T) {
+			opts := []Option{
+				WithMaxEntries(stressMaxEntries),
+				WithMaxBytes(stressMaxBytes),
+				WithRevalidateWindow(stressExpiration),
+				WithStaleWhileRevalidate(stressExpiration),
+			}
+			if disk {
+				opts = append(opts, WithDiskDir(t.TempDir(), stressExpiration))
+			}
+			stressCache(t, NewCache(stressExpiration, opts...))
+		})
+	}
+}
+
+func stressCache(t *testing.T, c *Cache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		c.ReadLoop(ctx)
+	}()
+
+	deadline := time.Now().Add(stressDuration)
+	var wg sync.WaitGroup
+	for w := range stressWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; time.Now().Before(deadline); i++ {
+				key := fmt.Sprintf("key-%d", (w+i)%stressKeys)
+				val := []byte(key)
+				switch i % 4 {
+				case 0:
+					c.Add(key, val)
+				case 1:
+					c.AddEntry(key, Entry{Val: val, ETag: `"` + key + `"`})
+				case 2:
+					if got, found := c.Get(key); found && string(got) != key {
+						t.Errorf("Get(%q) = %q", key, got)
+					}
+				case 3:
+					e, err := c.GetOrLoadEntry(ctx, key, func(ctx context.Context, stale *Entry) (Entry, error) {
+						// returning the stale entry renews it
+						if stale != nil && i%8 == 3 {
+							return *stale, nil
+						}
+						return Entry{Val: val}, nil
+					})
+					if err != nil {
+						t.Errorf("GetOrLoadEntry(%q): %v", key, err)
+					} else if string(e.Val) != key {
+						t.Errorf("GetOrLoadEntry(%q) = %q", key, e.Val)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	<-loopDone
+	c.Close()
+
+	stats := c.Stats()
+	if stats.Entries > stressMaxEntries {
+		t.Errorf("Entries = %d, want at most %d", stats.Entries, stressMaxEntries)
+	}
+	if stats.Bytes > stressMaxBytes {
+		t.Errorf("Bytes = %d, want at most %d", stats.Bytes, stressMaxBytes)
+	}
+}
+
+// TestTypedCacheConcurrentStress does the same for TypedCache
+func TestTypedCacheConcurrentStress(t *testing.T) {
+	c := NewTypedCache[int, int](stressExpiration, stressMaxEntries)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Start(ctx)
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		c.ReadLoop(ctx)
+	}()
+
+	deadline := time.Now().Add(stressDuration)
+	var wg sync.WaitGroup
+	for w := range stressWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; time.Now().Before(deadline); i++ {
+				key := (w + i) % stressKeys
+				if i%2 == 0 {
+					c.Add(key, key*key)
+				} else if got, found := c.Get(key); found && got != key*key {
+					t.Errorf("Get(%d) = %d", key, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+	<-loopDone
+	c.Close()
+
+	if entries := c.Stats().Entries; entries > stressMaxEntries {
+		t.Errorf("Entries = %d, want at most %d", entries, stressMaxEntries)
+	}
+}
+
+// TestGetOrLoadCoalesces checks that concurrent misses share one load
+func TestGetOrLoadCoalesces(t *testing.T) {
+	c := NewCache(time.Minute)
+	defer c.Close()
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range stressWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, error) {
+				loads.Add(1)
+				<-release
+				return []byte("val"), nil
+			})
+			if err != nil || string(val) != "val" {
+				t.Errorf("GetOrLoad = %q, %v", val, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+}
+
+// TestGetOrLoadPanicReleasesKey checks that a panicking loader does not
+// leave the key blocked for waiters and later callers
+func TestGetOrLoadPanicReleasesKey(t *testing.T) {
+	c := NewCache(time.Minute)
+	defer c.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicked := make(chan any)
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waitErr := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, error) {
+			return []byte("waiter"), nil
+		})
+		waitErr <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if p := <-panicked; p != "boom" {
+		t.Fatalf("recovered %v, want the loader panic", p)
+	}
+	select {
+	case err := <-waitErr:
+		// a waiter that joined the panicked load gets errLoadPanicked, one
+		// that came after it runs its own load
+		if err != nil && !errors.Is(err, errLoadPanicked) {
+			t.Errorf("waiter got %v, want errLoadPanicked", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter still blocked after the load panicked")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	val, err := c.GetOrLoad(ctx, "key", func(ctx context.Context) ([]byte, error) {
+		return []byte("val"), nil
+	})
+	if err != nil || (string(val) != "val" && string(val) != "waiter") {
+		t.Errorf("GetOrLoad after panic = %q, %v", val, err)
+	}
+}
+
+// TestDiskRemoveExpired checks that the janitor removes disk entries by the
+// modification time of their files and get removes unreadable ones
+func TestDiskRemoveExpired(t *testing.T) {
+	c := NewCache(time.Minute, WithDiskDir(t.TempDir(), 20*time.Millisecond))
+	defer c.Close()
+
+	c.Add("old", []byte("old"))
+	time.Sleep(40 * time.Millisecond)
+	c.Add("new", []byte("new"))
+	bad := c.disk.path("bad")
+	if err := os.WriteFile(bad, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(bad, time.Time{}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	c.clean()
+	if _, err := os.Stat(c.disk.path("old")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expired entry still on disk: %v", err)
+	}
+	if _, err := os.Stat(c.disk.path("new")); err != nil {
+		t.Errorf("fresh entry removed: %v", err)
+	}
+	if _, _, found := c.disk.get("bad"); found {
+		t.Error("get found an unreadable entry")
+	}
+	if _, err := os.Stat(bad); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unreadable entry still on disk after get: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package pokecache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type cacheEntry[K comparable, V any] struct {
+	key        K
+	expTime    time.Time
+	staleUntil time.Time // expired entries are kept until then, see store.retention
+	val        V
+	size       int
+}
+
+// store is the in memory part shared by Cache and TypedCache: a map of
+// expiring entries with optional LRU eviction. Every access, including
+// lookups that update the LRU order or drop expired entries, holds mu
+// exclusively. Expired entries can be retained for a while so they can
+// still be revalidated.
+type store[K comparable, V any] struct {
+	mu         sync.Mutex
+	cacheMap   map[K]*list.Element
+	lru        *list.List // front is the most recently used entry
+	expiration time.Duration
+	sizeOf     func(key K, val V) int    // nil when entries are not sized
+	retention  func(val V) time.Duration // how long to keep val after it expired, nil for never
+	maxEntries int
+	maxBytes   int
+	bytes      int
+	evictions  uint64
+	expired    uint64
+}
+
+func newStore[K comparable, V any](expiration time.Duration) *store[K, V] {
+	s := new(store[K, V])
+	s.cacheMap = make(map[K]*list.Element)
+	s.lru = list.New()
+	s.expiration = expiration
+	return s
+}
+
+// get: looks up a fresh value for key and marks it as recently used
+func (s *store[K, V]) get(key K) (V, bool) {
+	val, _, found := s.lookup(key, false)
+	return val, found
+}
+
+// getStale: like get but also returns expired values that are still
+// retained, together with the time the value expired or will expire
+func (s *store[K, V]) getStale(key K) (V, time.Time, bool) {
+	return s.lookup(key, true)
+}
+
+func (s *store[K, V]) lookup(key K, allowStale bool) (V, time.Time, bool) {
+	var zero V
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, exists := s.cacheMap[key]
+	if !exists {
+		return zero, time.Time{}, false
+	}
+	ce := elem.Value.(*cacheEntry[K, V])
+	now := time.Now()
+	if now.After(ce.staleUntil) {
+		s.remove(elem)
+		s.expired++
+		return zero, time.Time{}, false
+	}
+	// if current time is after the expiration time
+	if now.After(ce.expTime) && !allowStale {
+		return zero, time.Time{}, false
+	}
+	s.lru.MoveToFront(elem)
+	return ce.val, ce.expTime, true
+}
+
+func (s *store[K, V]) add(key K, val V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(key, val)
+}
+
+// addIfAbsent: adds val unless a fresh value for key is already present and
+// returns the value that ends up in the store
+func (s *store[K, V]) addIfAbsent(key K, val V) V {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, exists := s.cacheMap[key]; exists {
+		if ce := elem.Value.(*cacheEntry[K, V]); !time.Now().After(ce.expTime) {
+			return ce.val
+		}
+	}
+	s.addLocked(key, val)
+	return val
+}
+
+// addLocked: stores an entry and evicts old entries until the store is
+// within its limits. Must be called with mu held.
+func (s *store[K, V]) addLocked(key K, val V) {
+	if elem, exists := s.cacheMap[key]; exists {
+		s.remove(elem)
+	}
+	ce := &cacheEntry[K, V]{key: key, expTime: time.Now().Add(s.expiration), val: val}
+	ce.staleUntil = ce.expTime
+	if s.retention != nil {
+		ce.staleUntil = ce.staleUntil.Add(s.retention(val))
+	}
+	if s.sizeOf != nil {
+		ce.size = s.sizeOf(key, val)
+	}
+	if s.maxBytes > 0 && ce.size > s.maxBytes {
+		// would evict everything else and still not fit
+		s.evictions++
+		return
+	}
+	s.cacheMap[key] = s.lru.PushFront(ce)
+	s.bytes += ce.size
+
+	for (s.maxEntries > 0 && s.lru.Len() > s.maxEntries) || (s.maxBytes > 0 && s.bytes > s.maxBytes) {
+		s.remove(s.lru.Back())
+		s.evictions++
+	}
+}
+
+// remove: drops an entry. Must be called with mu held.
+func (s *store[K, V]) remove(elem *list.Element) {
+	ce := s.lru.Remove(elem).(*cacheEntry[K, V])
+	delete(s.cacheMap, ce.key)
+	s.bytes -= ce.size
+}
+
+func (s *store[K, V]) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, elem := range s.cacheMap {
+		if now.After(elem.Value.(*cacheEntry[K, V]).staleUntil) {
+			s.remove(elem)
+			s.expired++
+		}
+	}
+}
+
+func (s *store[K, V]) stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		Entries:   s.lru.Len(),
+		Bytes:     s.bytes,
+		Evictions: s.evictions,
+		Expired:   s.expired,
+	}
+}
+
+// janitor runs a clean up function on every tick of a ticker until it is
+// closed or its context is cancelled
+type janitor struct {
+	ticker    *time.Ticker
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newJanitor(interval time.Duration) *janitor {
+	j := new(janitor)
+	j.ticker = time.NewTicker(interval)
+	j.done = make(chan struct{})
+	return j
+}
+
+func (j *janitor) start(ctx context.Context, clean func()) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.loop(ctx, clean)
+	}()
+}
+
+func (j *janitor) loop(ctx context.Context, clean func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.done:
+			return
+		case <-j.ticker.C:
+		}
+		clean()
+	}
+}
+
+func (j *janitor) close() {
+	j.closeOnce.Do(func() {
+		close(j.done)
+		j.ticker.Stop()
+	})
+	j.wg.Wait()
+}
@@ -0,0 +1,58 @@
+package pokecache
+
+import (
+	"context"
+	"time"
+)
+
+// TypedCache holds decoded values so hits skip unmarshalling. It keeps
+// values in memory only, use Cache for raw bodies that should reach disk.
+// It is safe for concurrent use. Values are shared between callers and
+// must not be modified after they are added.
+type TypedCache[K comparable, V any] struct {
+	mem     *store[K, V]
+	janitor *janitor
+}
+
+// NewTypedCache: create a new empty typed cache. When maxEntries is more
+// than zero the least recently used entries are evicted beyond that count.
+func NewTypedCache[K comparable, V any](expiration time.Duration, maxEntries int) *TypedCache[K, V] {
+	c := new(TypedCache[K, V])
+	c.mem = newStore[K, V](expiration)
+	c.mem.maxEntries = maxEntries
+	c.janitor = newJanitor(expiration)
+	return c
+}
+
+// Add: add a value to the cache
+func (c *TypedCache[K, V]) Add(key K, val V) {
+	c.mem.add(key, val)
+}
+
+// Get: get the value for a key
+func (c *TypedCache[K, V]) Get(key K) (V, bool) {
+	return c.mem.get(key)
+}
+
+// Stats: returns the current number of entries and eviction counters.
+// Typed entries are not sized so Bytes is always zero.
+func (c *TypedCache[K, V]) Stats() Stats {
+	return c.mem.stats()
+}
+
+// Start: runs ReadLoop in a new goroutine until ctx is cancelled or the
+// cache is closed
+func (c *TypedCache[K, V]) Start(ctx context.Context) {
+	c.janitor.start(ctx, c.mem.removeExpired)
+}
+
+// Close: stops the ticker and waits for goroutines started with Start to return
+func (c *TypedCache[K, V]) Close() {
+	c.janitor.close()
+}
+
+// ReadLoop: loops around the cache and clears out expired data
+// until ctx is cancelled or the cache is closed
+func (c *TypedCache[K, V]) ReadLoop(ctx context.Context) {
+	c.janitor.loop(ctx, c.mem.removeExpired)
+}